@@ -3,6 +3,10 @@ module golift.io/turbovanityurls
 go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.5.0
+	golang.org/x/image v0.18.0
 	golift.io/badgedata v0.0.4
 	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/text v0.16.0 // indirect
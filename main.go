@@ -2,6 +2,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -13,12 +14,24 @@ import (
 var Version = "development" //nolint:gochecknoglobals
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convert(os.Args[2:])
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		importConfig(os.Args[2:])
+	}
+
 	flags := service.ParseFlags(os.Args[1:])
 	if flags.ShowVer {
 		fmt.Printf("turbovanityurls v%v\n", Version)
 		os.Exit(0)
 	}
 
+	if flags.Validate {
+		validate(flags)
+	}
+
 	server, err := service.Setup(flags)
 	if err != nil {
 		log.Fatal(err)
@@ -28,3 +41,116 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// validate prints config warnings, and the expanded config if requested, then exits.
+// The exit code is 1 if the config has errors.
+func validate(flags *service.Flags) {
+	config, warnings, err := service.Validate(flags)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flags.Expand {
+		data, err := config.Expanded()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Print(string(data))
+	}
+
+	for _, warning := range warnings {
+		fmt.Println("WARNING:", warning)
+	}
+
+	fmt.Printf("Config OK: %s (%d warnings)\n", flags.ConfigPath, len(warnings))
+	os.Exit(0)
+}
+
+// convert translates a config file from one format to another, then exits.
+// The output is written to stdout if no output file is provided.
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("f", "", "input format: yaml, json or toml; default is from the input file extension")
+	to := flags.String("to", "", "output format: yaml, json or toml; default is from the output file extension")
+	flags.Usage = func() {
+		fmt.Println("Usage: turbovanityurls convert [-f <format>] [-to <format>] <input-file> [<output-file>]")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 || (output == "" && *to == "") {
+		flags.Usage()
+		os.Exit(2) //nolint:mnd
+	}
+
+	inFormat, err := service.FileFormat(input, *from)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	outFormat, err := service.FileFormat(output, *to)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if data, err = service.Convert(data, inFormat, outFormat); err != nil {
+		log.Fatalf("%s: %v", input, err)
+	}
+
+	if output == "" {
+		_, _ = os.Stdout.Write(data)
+	} else if err := os.WriteFile(output, data, 0o644); err != nil { //nolint:gosec,mnd
+		log.Fatal(err)
+	}
+
+	os.Exit(0)
+}
+
+// importConfig converts a govanityurls or sally config file into a turbovanityurls config, then exits.
+// The output is written to stdout if no output file is provided. Warnings are written to stderr.
+func importConfig(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	from := flags.String("from", "", "input format: govanityurls or sally; default is detected from the file")
+	flags.Usage = func() {
+		fmt.Println("Usage: turbovanityurls import [-from <format>] <input-file> [<output-file>]")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		os.Exit(2) //nolint:mnd
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, warnings, err := service.Import(data, *from)
+	if err != nil {
+		log.Fatalf("%s: %v", input, err)
+	}
+
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "WARNING:", warning)
+	}
+
+	if output == "" {
+		_, _ = os.Stdout.Write(data)
+	} else if err := os.WriteFile(output, data, 0o644); err != nil { //nolint:gosec,mnd
+		log.Fatal(err)
+	}
+
+	os.Exit(0)
+}
@@ -0,0 +1,230 @@
+// Package bdcache provides a response cache for the badgedata handler.
+// Badge data comes from upstream providers (like grafana.com), and those
+// requests are slow and may fail. This cache serves fresh responses from memory,
+// serves stale responses while revalidating in the background, and serves the
+// last good response when the upstream provider fails.
+package bdcache
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for the cache config.
+const (
+	DefaultTTL     = 10 * time.Minute
+	DefaultStale   = time.Hour
+	DefaultTimeout = 10 * time.Second
+	// maxEntries is how many responses are kept before the cache is reset.
+	maxEntries = 1000
+)
+
+// Config controls the cache.
+type Config struct {
+	// TTL is how long a response is fresh.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+	// Stale is how long after TTL a response may be served while it's refreshed in the background.
+	Stale time.Duration `yaml:"stale,omitempty"`
+	// Timeout is how long to wait for the upstream provider.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Providers is an allowlist of badgedata providers, like grafana. Empty allows all.
+	Providers []string `yaml:"providers,omitempty"`
+}
+
+// Cache wraps a badgedata handler.
+type Cache struct {
+	*Config
+	prefix  string
+	next    http.Handler
+	mu      sync.Mutex
+	entries map[string]*entry
+	now     func() time.Time
+}
+
+// entry is a cached response.
+type entry struct {
+	header     http.Header
+	body       []byte
+	stored     time.Time
+	refreshing bool
+}
+
+// recorder captures a response from the upstream handler.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// New returns a caching handler. Prefix is the path the handler is mounted on, like /bd/.
+func New(next http.Handler, prefix string, config *Config) *Cache {
+	if config == nil {
+		config = &Config{}
+	}
+
+	if config.TTL == 0 {
+		config.TTL = DefaultTTL
+	}
+
+	if config.Stale == 0 {
+		config.Stale = DefaultStale
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	return &Cache{
+		Config:  config,
+		prefix:  prefix,
+		next:    http.TimeoutHandler(next, config.Timeout, "upstream provider timed out"),
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// SetClock replaces the clock used for expiring entries. Used in tests.
+func (c *Cache) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (c *Cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.allowed(r.URL.Path) {
+		http.Error(w, "provider not allowed", http.StatusNotFound)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		c.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.URL.RequestURI()
+
+	c.mu.Lock()
+	cached := c.entries[key]
+	age := time.Duration(0)
+
+	if cached != nil {
+		age = c.now().Sub(cached.stored)
+	}
+
+	switch {
+	case cached != nil && age < c.TTL:
+		// Fresh.
+		c.mu.Unlock()
+		cached.write(w)
+
+		return
+	case cached != nil && age < c.TTL+c.Stale:
+		// Stale, but usable. Refresh it in the background.
+		if !cached.refreshing {
+			cached.refreshing = true
+
+			go c.refresh(r.Clone(context.Background()), key)
+		}
+
+		c.mu.Unlock()
+		cached.write(w)
+
+		return
+	}
+
+	c.mu.Unlock()
+
+	rec := c.fetch(r)
+
+	switch {
+	case rec.status < http.StatusInternalServerError:
+		rec.write(w)
+	case cached != nil:
+		// Upstream failed; serve the last good value.
+		cached.write(w)
+	default:
+		rec.write(w)
+	}
+}
+
+// allowed checks the provider in the request path against the allowlist.
+func (c *Cache) allowed(path string) bool {
+	if len(c.Providers) == 0 {
+		return true
+	}
+
+	provider := strings.Split(strings.TrimPrefix(path, c.prefix), "/")[0]
+
+	for _, allowed := range c.Providers {
+		if provider == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// refresh fetches a stale entry again. The old value is kept if the upstream fails.
+func (c *Cache) refresh(r *http.Request, key string) {
+	c.fetch(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached := c.entries[key]; cached != nil {
+		cached.refreshing = false
+	}
+}
+
+// fetch calls the upstream handler and stores good responses in the cache.
+func (c *Cache) fetch(r *http.Request) *recorder {
+	rec := &recorder{header: make(http.Header), status: http.StatusOK}
+	c.next.ServeHTTP(rec, r)
+
+	if rec.status != http.StatusOK {
+		return rec
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxEntries {
+		c.entries = make(map[string]*entry)
+	}
+
+	c.entries[r.URL.RequestURI()] = &entry{header: rec.header.Clone(), body: rec.body.Bytes(), stored: c.now()}
+
+	return rec
+}
+
+func (e *entry) write(w http.ResponseWriter) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+
+	_, _ = w.Write(e.body)
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b) //nolint:wrapcheck
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) write(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+
+	w.WriteHeader(r.status)
+	_, _ = w.Write(r.body.Bytes())
+}
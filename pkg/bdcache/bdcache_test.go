@@ -0,0 +1,115 @@
+//nolint:noctx
+package bdcache_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golift.io/turbovanityurls/pkg/bdcache"
+)
+
+// provider is a local stand-in for an upstream badgedata provider.
+type provider struct {
+	calls atomic.Int64
+	fail  atomic.Bool
+}
+
+func (p *provider) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	call := p.calls.Add(1)
+	if p.fail.Load() {
+		http.Error(w, "upstream down", http.StatusBadGateway)
+		return
+	}
+
+	_, _ = w.Write([]byte("value-" + strconv.FormatInt(call, 10)))
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	upstream := &provider{}
+	clk := &clock{now: time.Now()}
+	cache := bdcache.New(upstream, "/bd/", &bdcache.Config{
+		TTL:       time.Minute,
+		Stale:     time.Hour,
+		Providers: []string{"grafana"},
+	})
+	cache.SetClock(clk.Now)
+
+	s := httptest.NewServer(cache)
+	defer s.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return resp.StatusCode, string(data)
+	}
+
+	if _, body := get("/bd/grafana/dashboard"); body != "value-1" {
+		t.Errorf("first request must reach the provider, got %q", body)
+	}
+
+	if _, body := get("/bd/grafana/dashboard"); body != "value-1" || upstream.calls.Load() != 1 {
+		t.Errorf("fresh request must be served from cache, got %q after %d calls", body, upstream.calls.Load())
+	}
+
+	// Stale: the old value is returned and a refresh happens in the background.
+	clk.Add(2 * time.Minute)
+
+	if _, body := get("/bd/grafana/dashboard"); body != "value-1" {
+		t.Errorf("stale request must be served from cache, got %q", body)
+	}
+
+	body := ""
+	for i := 0; i < 100 && body != "value-2"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, body = get("/bd/grafana/dashboard")
+	}
+
+	if body != "value-2" || upstream.calls.Load() != 2 {
+		t.Errorf("refreshed value must be served after revalidation, got %q after %d calls", body, upstream.calls.Load())
+	}
+
+	// Expired and the upstream is failing: the last good value is returned.
+	upstream.fail.Store(true)
+	clk.Add(2 * time.Hour)
+
+	if status, body := get("/bd/grafana/dashboard"); status != http.StatusOK || body != "value-2" {
+		t.Errorf("upstream failure must serve the last good value, got %d %q", status, body)
+	}
+
+	if status, _ := get("/bd/other/thing"); status != http.StatusNotFound {
+		t.Errorf("providers not in the allowlist must return 404, got %d", status)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrAliasConflict is returned when an alias is used twice, or matches a configured path.
+var ErrAliasConflict = errors.New("alias conflicts with another path or alias")
+
+// setAliases builds the lookup table used to normalize request paths. Keys are
+// lowercase paths and aliases, values are the configured (canonical) paths.
+// Configured paths that only differ by case are ambiguous, and never normalized.
+func (h *Handler) setAliases() error {
+	h.aliases = make(map[string]string)
+
+	for _, p := range h.PathConfigs {
+		key := strings.ToLower(p.Path)
+		if _, ok := h.aliases[key]; ok {
+			h.aliases[key] = ""
+		} else {
+			h.aliases[key] = p.Path
+		}
+	}
+
+	for _, p := range h.PathConfigs {
+		for _, alias := range p.Aliases {
+			key := strings.ToLower(alias)
+			if !strings.HasPrefix(key, "/") || key == "/" {
+				return fmt.Errorf("%w: %s: alias must start with / and cannot be /: %q", ErrAliasConflict, p.Path, alias)
+			}
+
+			if other, ok := h.aliases[key]; ok {
+				return fmt.Errorf("%w: %s: alias %q is already used by %s", ErrAliasConflict, p.Path, alias, other)
+			}
+
+			h.aliases[key] = p.Path
+		}
+	}
+
+	return nil
+}
+
+// canonical returns the canonical request path for a path that uses an alias,
+// different letter case, or an extra or missing trailing slash. The prefix is the
+// part of the request path that matched. Returns an empty string if path is canonical.
+func (h *Handler) canonical(path string) (string, string) {
+	var (
+		key     string
+		matched int
+	)
+
+	for alias := range h.aliases {
+		trimmed := strings.TrimSuffix(alias, "/")
+		if length := foldedPrefix(path, trimmed); trimmed != "" && length > matched {
+			key, matched = trimmed, length
+		}
+	}
+
+	target := h.aliases[key]
+	if target == "" {
+		target = h.aliases[key+"/"]
+	}
+
+	if matched == 0 || target == "" {
+		return "", ""
+	}
+
+	canonical := target
+	if rest := path[matched:]; rest != "" && rest != "/" {
+		canonical = strings.TrimSuffix(target, "/") + rest
+	}
+
+	if canonical == path {
+		return "", ""
+	}
+
+	return canonical, path[:matched]
+}
+
+// foldedPrefix returns how many bytes at the start of path match alias, ignoring letter
+// case, or -1. Matches end between path segments. Changing the case of a string can change
+// its length, so the segments are compared one at a time and the length is from path.
+func foldedPrefix(path, alias string) int {
+	pathSegments, aliasSegments := strings.Split(path, "/"), strings.Split(alias, "/")
+	if len(aliasSegments) > len(pathSegments) {
+		return -1
+	}
+
+	length := len(aliasSegments) - 1 // The slashes.
+
+	for i, segment := range aliasSegments {
+		if !strings.EqualFold(segment, pathSegments[i]) {
+			return -1
+		}
+
+		length += len(pathSegments[i])
+	}
+
+	return length
+}
+
+// find returns the configured path for a request. Browsers that request an alias
+// or a non-canonical path get a redirect target instead. go-get clients get the
+// canonical path's config, with the import path they requested, so the meta tags match.
+func (h *Handler) find(r *http.Request) (PathReq, string) {
+	canonical, prefix := h.canonical(r.URL.Path)
+	if canonical == "" {
+		return h.PathConfigs.findActive(r.URL.Path), ""
+	}
+
+	if r.URL.Query().Get("go-get") != "1" {
+		if r.URL.RawQuery != "" {
+			canonical += "?" + r.URL.RawQuery
+		}
+
+		return PathReq{}, canonical
+	}
+
+	pc := h.PathConfigs.findActive(canonical)
+	if pc.PathConfig != nil && strings.HasSuffix(pc.Path, "/") {
+		prefix += "/"
+	}
+
+	pc.importPrefix = prefix
+
+	return pc, ""
+}
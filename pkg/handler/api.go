@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// APIPath is the JSON representation of a configured path.
+type APIPath struct {
+	Path        string   `json:"path"`
+	ImportPath  string   `json:"importPath,omitempty"`
+	Repo        string   `json:"repo,omitempty"`
+	VCS         string   `json:"vcs,omitempty"`
+	Redir       string   `json:"redir,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Wildcard    bool     `json:"wildcard,omitempty"`
+	Listed      bool     `json:"listed"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	ReplacedBy  string   `json:"replacedBy,omitempty"`
+	Aliases     []string `json:"aliases,omitempty"`
+}
+
+// setAPIRoutes adds the JSON API routes, if api_path is set.
+// The stats endpoint requires the stats credentials, so it's only added if they're set.
+func (h *Handler) setAPIRoutes() {
+	if h.APIPath == "" {
+		return
+	}
+
+	base := strings.TrimSuffix(h.APIPath, "/")
+	h.routes[base+"/paths"] = h.APIPaths
+
+	if h.Stats.Username != "" && h.Stats.Password != "" {
+		h.routes[base+"/stats"] = h.APIStats
+	}
+}
+
+// APIPaths returns every configured path as JSON.
+func (h *Handler) APIPaths(w http.ResponseWriter, _ *http.Request) {
+	paths := make([]*APIPath, 0, len(h.PathConfigs))
+
+	for _, p := range h.PathConfigs {
+		if !p.Active() {
+			continue
+		}
+
+		path := &APIPath{
+			Path:        p.Path,
+			Repo:        p.Repo,
+			VCS:         p.VCS,
+			Redir:       p.Redir,
+			Name:        p.Name,
+			Description: p.Description,
+			Wildcard:    p.Wildcard,
+			Listed:      p.Listed(),
+			Deprecated:  p.Deprecated,
+			ReplacedBy:  p.ReplacedBy,
+			Aliases:     p.Aliases,
+		}
+
+		if p.Repo != "" && !p.Wildcard {
+			path.ImportPath = h.Host + strings.TrimSuffix(p.Path, "/")
+		}
+
+		paths = append(paths, path)
+	}
+
+	writeJSON(w, paths)
+}
+
+// APIStats returns request counters for each import path as JSON.
+func (h *Handler) APIStats(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, h.stats.Report())
+}
+
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, "cannot encode json", http.StatusInternalServerError)
+	}
+}
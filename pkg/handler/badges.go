@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// badgeSegment separates a vanity path from a badge name in badge URLs.
+// Example: /unifi/-/badge/import.svg
+const badgeSegment = "-/badge"
+
+// Badge rendering values. Text widths are estimated, like most badge services do.
+const (
+	badgeHeight    = 20
+	badgePadding   = 6
+	badgeCharWidth = 7
+	badgeFontSize  = 11
+	badgeTextY     = 14
+)
+
+// Default badge colors and labels.
+const (
+	defaultBadgeLabelColor = "#555"
+	defaultBadgeColor      = "#007d9c"
+	defaultImportLabel     = "import"
+	defaultReferenceLabel  = "go"
+	defaultFetchesLabel    = "go get"
+)
+
+// BadgeConfig controls the built-in SVG badges. Each vanity path has badges at
+// /<path>/-/badge/import.svg, /<path>/-/badge/reference.svg and /<path>/-/badge/fetches.svg.
+type BadgeConfig struct {
+	Disable        bool   `yaml:"disable,omitempty"`
+	LabelColor     string `yaml:"label_color,omitempty"`
+	Color          string `yaml:"color,omitempty"`
+	ImportLabel    string `yaml:"import_label,omitempty"`
+	ReferenceLabel string `yaml:"reference_label,omitempty"`
+	FetchesLabel   string `yaml:"fetches_label,omitempty"`
+}
+
+// setDefaults fills in empty badge values.
+func (b *BadgeConfig) setDefaults() {
+	if b.LabelColor == "" {
+		b.LabelColor = defaultBadgeLabelColor
+	}
+
+	if b.Color == "" {
+		b.Color = defaultBadgeColor
+	}
+
+	if b.ImportLabel == "" {
+		b.ImportLabel = defaultImportLabel
+	}
+
+	if b.ReferenceLabel == "" {
+		b.ReferenceLabel = defaultReferenceLabel
+	}
+
+	if b.FetchesLabel == "" {
+		b.FetchesLabel = defaultFetchesLabel
+	}
+}
+
+// isBadgePath returns true if the subpath is for a built-in badge.
+func isBadgePath(subpath string) bool {
+	_, _, ok := splitSubpath(subpath, badgeSegment)
+	return ok
+}
+
+// serveBadge renders an SVG badge for a vanity path.
+func (h *Handler) serveBadge(w http.ResponseWriter, r *http.Request, pc *PathReq) {
+	prefix, badge, _ := splitSubpath(pc.Subpath, badgeSegment)
+	if !pc.wildcardPrefix(prefix) {
+		h.NotFound(w, r)
+		return
+	}
+
+	var label, message string
+
+	switch badge {
+	case "import.svg":
+		label, message = h.Badges.ImportLabel, pc.Host+pc.ImportPath()
+	case "reference.svg":
+		label, message = h.Badges.ReferenceLabel, "reference"
+	case "fetches.svg":
+		label, message = h.Badges.FetchesLabel, strconv.FormatUint(h.stats.Total(pc.configuredPath(), kindGoGet), 10)
+	default:
+		h.NotFound(w, r)
+		return
+	}
+
+	// Badges change often, and they're cheap to make. Let proxies cache them for a few minutes.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write([]byte(renderBadge(label, message, h.Badges.LabelColor, h.Badges.Color)))
+}
+
+// BadgeMarkdown is used in the template to show README markdown for the reference badge.
+// The badge links to the configured documentation site.
+func (p *PathReq) BadgeMarkdown() string {
+	return fmt.Sprintf("[![Go Reference](https://%s%s/%s/reference.svg)](%s)",
+		p.Host, p.ImportPath(), badgeSegment, p.DocsLink())
+}
+
+// renderBadge returns a flat, two-part SVG badge.
+func renderBadge(label, message, labelColor, color string) string {
+	labelWidth := len([]rune(label))*badgeCharWidth + 2*badgePadding
+	messageWidth := len([]rune(message))*badgeCharWidth + 2*badgePadding
+	label, message = html.EscapeString(label), html.EscapeString(message)
+
+	var svg strings.Builder
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="%s: %s">`,
+		labelWidth+messageWidth, badgeHeight, label, message)
+	fmt.Fprintf(&svg, `<title>%s: %s</title>`, label, message)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="%s"/>`, labelWidth, badgeHeight, html.EscapeString(labelColor))
+	fmt.Fprintf(&svg, `<rect x="%d" width="%d" height="%d" fill="%s"/>`,
+		labelWidth, messageWidth, badgeHeight, html.EscapeString(color))
+	fmt.Fprintf(&svg, `<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="%d">`,
+		badgeFontSize)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d">%s</text>`, labelWidth/2, badgeTextY, label)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d">%s</text>`, labelWidth+messageWidth/2, badgeTextY, message)
+	svg.WriteString(`</g></svg>`)
+
+	return svg.String()
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrUnknownProfile is returned when a path uses a profile that is not configured.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// noInherit are PathConfig fields that are never copied from defaults or profiles.
+var noInherit = map[string]bool{"Path": true, "Profile": true, "Aliases": true} //nolint:gochecknoglobals
+
+// noExpand are PathConfig fields with their own placeholders, filled in per request.
+var noExpand = map[string]bool{"RepoTemplate": true, "DocsURL": true} //nolint:gochecknoglobals
+
+// setDefaults fills in empty path values from the path's profile, then from the defaults block.
+// Placeholders in string values are replaced afterward: {name} is the last path segment,
+// {path} is the path without slashes around it, {host} is the host and {repo} is the repo.
+func (p *PathConfig) setDefaults(c *Config) error {
+	if p.Profile != "" {
+		profile := c.Profiles[p.Profile]
+		if profile == nil {
+			return fmt.Errorf("%w: %s: %s", ErrUnknownProfile, p.Path, p.Profile)
+		}
+
+		p.inherit(profile)
+	}
+
+	if c.Defaults != nil {
+		p.inherit(c.Defaults)
+	}
+
+	p.expand(c.Host)
+
+	return nil
+}
+
+// inherit copies every non-empty field from src that is empty in p.
+// Booleans can only be turned on this way, because false is empty.
+func (p *PathConfig) inherit(src *PathConfig) {
+	dst, from := reflect.ValueOf(p).Elem(), reflect.ValueOf(src).Elem()
+
+	for i := range dst.NumField() {
+		field := dst.Type().Field(i)
+		if !field.IsExported() || noInherit[field.Name] || !dst.Field(i).IsZero() || from.Field(i).IsZero() {
+			continue
+		}
+
+		value := from.Field(i)
+		if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct {
+			// Copy structs, so setup for one path does not change another.
+			value = reflect.New(value.Elem().Type())
+			value.Elem().Set(from.Field(i).Elem())
+		}
+
+		dst.Field(i).Set(value)
+	}
+}
+
+// expand replaces placeholders in the path's string values. Repo is expanded first, so others may use {repo}.
+func (p *PathConfig) expand(host string) {
+	path := strings.Trim(p.Path, "/")
+	pairs := []string{"{name}", path[strings.LastIndex(path, "/")+1:], "{path}", path, "{host}", host}
+
+	p.Repo = strings.NewReplacer(pairs...).Replace(p.Repo)
+	replacer := strings.NewReplacer(append(pairs, "{repo}", p.Repo)...)
+	value := reflect.ValueOf(p).Elem()
+
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if field.IsExported() && field.Type.Kind() == reflect.String && !noExpand[field.Name] {
+			value.Field(i).SetString(replacer.Replace(value.Field(i).String()))
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import "strings"
+
+// Documentation links use these when docs_url is not configured.
+const (
+	defaultDocsURL = "https://pkg.go.dev/{import}"
+	// builtinDocsURL is used for paths with a source_dir; they have built-in docs.
+	builtinDocsURL = "https://{import}/" + docSegment
+)
+
+// setDocsURL fills in the path's documentation URL template from the global config.
+func (p *PathConfig) setDocsURL(globalDocs string, docsSource bool) {
+	p.docsSource = docsSource
+
+	switch {
+	case p.DocsURL != "":
+	case p.SourceDir != "":
+		p.DocsURL = builtinDocsURL
+	case globalDocs != "":
+		p.DocsURL = globalDocs
+	default:
+		p.DocsURL = defaultDocsURL
+	}
+}
+
+// DocsLink is used in the template to generate the documentation link.
+// Replaces {import} with host+path, {host} with the host and {path} with the path.
+func (p *PathReq) DocsLink() string {
+	return strings.NewReplacer(
+		"{import}", p.Host+p.ImportPath(),
+		"{host}", p.Host,
+		"{path}", p.ImportPath(),
+	).Replace(p.DocsURL)
+}
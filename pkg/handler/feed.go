@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// atomFeed is the root of an Atom feed document.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Summary   string   `xml:"summary,omitempty"`
+}
+
+// Feed renders an Atom feed of listed paths, most recently added or changed first.
+func (h *Handler) Feed(w http.ResponseWriter, _ *http.Request) {
+	feed := &atomFeed{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		ID:      "https://" + h.Host + h.FeedPath,
+		Title:   h.Title,
+		Updated: h.Loaded.Format(time.RFC3339),
+		Link:    atomLink{Href: "https://" + h.Host + h.FeedPath, Rel: "self"},
+		Author:  atomAuthor{Name: h.Title},
+	}
+
+	for _, p := range h.PathConfigs {
+		hist := h.History[p.Path]
+		if !p.Listed() || hist == nil {
+			continue
+		}
+
+		url := "https://" + h.Host + strings.TrimSuffix(p.Path, "/")
+		title := "Added " + h.Host + strings.TrimSuffix(p.Path, "/")
+
+		if hist.Updated.After(hist.Added) {
+			title = "Updated " + h.Host + strings.TrimSuffix(p.Path, "/")
+		}
+
+		feed.Entries = append(feed.Entries, atomEntry{
+			// The ID changes with each update so feed readers announce it again.
+			ID:        url + "#" + hist.Updated.Format(time.RFC3339),
+			Title:     title,
+			Link:      atomLink{Href: url},
+			Published: hist.Added.Format(time.RFC3339),
+			Updated:   hist.Updated.Format(time.RFC3339),
+			Summary:   p.Description,
+		})
+	}
+
+	sort.SliceStable(feed.Entries, func(i, j int) bool {
+		return feed.Entries[i].Updated > feed.Entries[j].Updated
+	})
+
+	if len(feed.Entries) > 0 {
+		feed.Updated = feed.Entries[0].Updated
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(feed); err != nil {
+		http.Error(w, "cannot render the feed", http.StatusInternalServerError)
+	}
+}
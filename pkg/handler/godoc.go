@@ -0,0 +1,337 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"html"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golift.io/turbovanityurls/pkg/templates"
+)
+
+// docSegment separates a vanity path from a package path in documentation URLs.
+// Example: /unifi/-/doc/subpkg renders documentation for golift.io/unifi/subpkg.
+const docSegment = "-/doc"
+
+// ErrNoGoFiles is returned when a documentation directory has no Go files.
+var ErrNoGoFiles = errors.New("no buildable Go source files")
+
+// DocPackage is passed into the documentation template.
+// Doc and Decl fields contain HTML; they are escaped when created.
+type DocPackage struct {
+	Host       string
+	IndexTitle string
+	LogoURL    string
+	ImportPath string // full import path of the package, including host.
+	BasePath   string // URL path to the root documentation page.
+	PkgPath    string // package path relative to BasePath.
+	Name       string
+	Synopsis   string
+	Doc        string
+	Consts     []*DocValue
+	Vars       []*DocValue
+	Funcs      []*DocFunc
+	Types      []*DocType
+	Examples   []*DocExample
+	Subdirs    []string
+}
+
+// DocValue is a documented const or var block, or a func.
+type DocValue struct {
+	Doc  string
+	Decl string
+}
+
+// DocFunc is a documented function or method.
+type DocFunc struct {
+	Name     string
+	Doc      string
+	Decl     string
+	Examples []*DocExample
+}
+
+// DocType is a documented type and the values and functions associated with it.
+type DocType struct {
+	Name     string
+	Doc      string
+	Decl     string
+	Consts   []*DocValue
+	Vars     []*DocValue
+	Funcs    []*DocFunc
+	Methods  []*DocFunc
+	Examples []*DocExample
+}
+
+// DocExample is a testable example from a _test.go file.
+type DocExample struct {
+	Name   string
+	Doc    string
+	Code   string
+	Output string
+}
+
+// docCache keeps rendered package documentation until the files in its directory change.
+type docCache struct {
+	mu    sync.Mutex
+	pages map[string]*docCached
+}
+
+type docCached struct {
+	stamp string
+	pkg   *DocPackage
+}
+
+// splitSubpath splits a subpath into its (wildcard) prefix and the path after a
+// segment, like -/doc. Returns false if the segment is not in the subpath.
+func splitSubpath(subpath, segment string) (string, string, bool) {
+	switch idx := strings.Index(subpath, segment); {
+	case idx < 0, idx > 0 && subpath[idx-1] != '/':
+		return "", "", false
+	case len(subpath) > idx+len(segment) && subpath[idx+len(segment)] != '/':
+		return "", "", false
+	default:
+		return subpath[:idx], strings.Trim(subpath[idx+len(segment):], "/"), true
+	}
+}
+
+// isDocPath returns true if the subpath is for built-in documentation.
+func isDocPath(subpath string) bool {
+	_, _, ok := splitSubpath(subpath, docSegment)
+	return ok
+}
+
+// serveDocs renders package documentation from a path's local source checkout.
+func (h *Handler) serveDocs(w http.ResponseWriter, r *http.Request, pc *PathReq) {
+	prefix, pkgPath, _ := splitSubpath(pc.Subpath, docSegment)
+	if !pc.wildcardPrefix(prefix) || path.Clean("/"+pkgPath) != "/"+pkgPath {
+		// Only wildcard paths have a repo name before the doc segment.
+		// Package paths with dot segments or empty segments are not served.
+		h.NotFound(w, r)
+		return
+	}
+
+	root := pc.SourceDir
+
+	if pc.Wildcard {
+		// Wildcard checkouts live in a directory named after the repo, like team/project.
+		name, _, _ := pc.WildcardName()
+		root = filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash("/"+pkgPath))
+
+	pkg, err := h.docs.load(dir, pc.Host+pc.ImportPath()+strings.TrimSuffix("/"+pkgPath, "/"))
+	if err != nil {
+		h.NotFound(w, r)
+		return
+	}
+
+	page := *pkg // copy; the cached package is shared.
+	page.Host = pc.Host
+	page.IndexTitle = h.Title
+	page.LogoURL = h.LogoURL
+	page.BasePath = strings.TrimSuffix(pc.Path, "/") + "/" + prefix + docSegment
+	page.PkgPath = pkgPath
+
+	w.Header().Set("Cache-Control", pc.cacheControl)
+
+	if err := templates.Doc.Execute(w, &page); err != nil {
+		http.Error(w, "cannot render the page", http.StatusInternalServerError)
+	}
+}
+
+// load returns cached documentation for a directory, or parses it again if it changed.
+func (c *docCache) load(dir, importPath string) (*DocPackage, error) {
+	stamp, err := dirStamp(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached := c.pages[dir]; cached != nil && cached.stamp == stamp {
+		return cached.pkg, nil
+	}
+
+	pkg, err := parseDocs(dir, importPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c.pages[dir] = &docCached{stamp: stamp, pkg: pkg}
+
+	return pkg, nil
+}
+
+// dirStamp returns a string that changes whenever a Go file or subdirectory in dir changes.
+func dirStamp(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reading source directory: %w", err)
+	}
+
+	var stamp strings.Builder
+
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(&stamp, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+
+	return stamp.String(), nil
+}
+
+// parseDocs parses the Go files in a directory and builds its documentation.
+func parseDocs(dir, importPath string) (*DocPackage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading source directory: %w", err)
+	}
+
+	var (
+		fset    = token.NewFileSet()
+		files   = []*ast.File{}
+		subdirs = []string{}
+		name    string
+	)
+
+	for _, entry := range entries {
+		switch fileName := entry.Name(); {
+		case entry.IsDir():
+			if hasGoFiles(filepath.Join(dir, fileName)) {
+				subdirs = append(subdirs, fileName)
+			}
+		case !strings.HasSuffix(fileName, ".go"), strings.HasPrefix(fileName, "."), strings.HasPrefix(fileName, "_"):
+		default:
+			file, err := parser.ParseFile(fset, filepath.Join(dir, fileName), nil, parser.ParseComments)
+			if err != nil {
+				return nil, fmt.Errorf("parsing source file: %w", err)
+			}
+
+			if name == "" && !strings.HasSuffix(fileName, "_test.go") {
+				name = file.Name.Name
+			}
+
+			files = append(files, file)
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoGoFiles, dir)
+	}
+
+	// Skip files from other packages, like a `package main` generator with an ignore tag.
+	pkgFiles := files[:0]
+
+	for _, file := range files {
+		if file.Name.Name == name || file.Name.Name == name+"_test" {
+			pkgFiles = append(pkgFiles, file)
+		}
+	}
+
+	dpkg, err := doc.NewFromFiles(fset, pkgFiles, importPath)
+	if err != nil {
+		return nil, fmt.Errorf("building documentation: %w", err)
+	}
+
+	return newDocPackage(fset, dpkg, importPath, subdirs), nil
+}
+
+// hasGoFiles returns true if a directory (that is not testdata or hidden) has Go files.
+func hasGoFiles(dir string) bool {
+	if base := filepath.Base(dir); base == "testdata" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_") {
+		return false
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".go") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newDocPackage converts go/doc output into HTML-ready template data.
+func newDocPackage(fset *token.FileSet, dpkg *doc.Package, importPath string, subdirs []string) *DocPackage {
+	text := func(s string) string { return string(dpkg.HTML(s)) }
+	code := func(node any) string {
+		var buf bytes.Buffer
+		_ = format.Node(&buf, fset, node)
+
+		return html.EscapeString(buf.String())
+	}
+	values := func(list []*doc.Value) []*DocValue {
+		out := []*DocValue{}
+		for _, v := range list {
+			out = append(out, &DocValue{Doc: text(v.Doc), Decl: code(v.Decl)})
+		}
+
+		return out
+	}
+	examples := func(list []*doc.Example) []*DocExample {
+		out := []*DocExample{}
+		for _, e := range list {
+			out = append(out, &DocExample{
+				Name: strings.TrimPrefix(e.Suffix, "_"), Doc: text(e.Doc),
+				Code: code(e.Code), Output: html.EscapeString(e.Output),
+			})
+		}
+
+		return out
+	}
+	funcs := func(list []*doc.Func) []*DocFunc {
+		out := []*DocFunc{}
+		for _, f := range list {
+			out = append(out, &DocFunc{Name: f.Name, Doc: text(f.Doc), Decl: code(f.Decl), Examples: examples(f.Examples)})
+		}
+
+		return out
+	}
+
+	pkg := &DocPackage{
+		ImportPath: importPath,
+		Name:       dpkg.Name,
+		Synopsis:   dpkg.Synopsis(dpkg.Doc),
+		Doc:        text(dpkg.Doc),
+		Consts:     values(dpkg.Consts),
+		Vars:       values(dpkg.Vars),
+		Funcs:      funcs(dpkg.Funcs),
+		Examples:   examples(dpkg.Examples),
+		Subdirs:    subdirs,
+	}
+
+	for _, t := range dpkg.Types {
+		pkg.Types = append(pkg.Types, &DocType{
+			Name: t.Name, Doc: text(t.Doc), Decl: code(t.Decl),
+			Consts: values(t.Consts), Vars: values(t.Vars),
+			Funcs: funcs(t.Funcs), Methods: funcs(t.Methods),
+			Examples: examples(t.Examples),
+		})
+	}
+
+	sort.Strings(pkg.Subdirs)
+
+	return pkg
+}
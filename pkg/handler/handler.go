@@ -19,8 +19,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"golift.io/turbovanityurls/pkg/templates"
 )
@@ -38,15 +40,51 @@ type Config struct {
 	CacheAge   *uint64                `yaml:"cache_max_age,omitempty"`
 	Paths      map[string]*PathConfig `yaml:"paths,omitempty"`
 	RedirPaths []string               `yaml:"redir_paths,omitempty"`
+	RedirMatch string                 `yaml:"redir_match,omitempty"`
+	Redirects  []*RedirectRule        `yaml:"redirects,omitempty"`
 	Src        string                 `yaml:"src,omitempty"`
 	RedirIndex string                 `yaml:"redir_index,omitempty"`
 	Redir404   string                 `yaml:"redir_404,omitempty"`
+	Robots     RobotsConfig           `yaml:"robots,omitempty"`
+	Social     SocialConfig           `yaml:"social,omitempty"`
+	Preview    PreviewConfig          `yaml:"preview,omitempty"`
+	Badges     BadgeConfig            `yaml:"badges,omitempty"`
+	DocsURL    string                 `yaml:"docs_url,omitempty"`
+	DocsSource bool                   `yaml:"docs_source,omitempty"`
+	FeedPath   string                 `yaml:"feed_path,omitempty"`
+	StateDir   string                 `yaml:"state_dir,omitempty"`
+	Stats      StatsConfig            `yaml:"stats,omitempty"`
+	APIPath    string                 `yaml:"api_path,omitempty"`
+	// HideDeprecated removes deprecated modules from the index page, instead of grouping them.
+	HideDeprecated bool `yaml:"hide_deprecated,omitempty"`
+	// Notices are site-wide banners, displayed on the index and package pages.
+	Notices []*Notice `yaml:"notices,omitempty"`
+	// Defaults fill in empty values for every path. Profiles are named defaults a path may use.
+	Defaults *PathConfig            `yaml:"defaults,omitempty"`
+	Profiles map[string]*PathConfig `yaml:"profiles,omitempty"`
+	clock    *clock
 }
 
 // Handler contains all the running data for our web server.
 type Handler struct {
 	*Config
 	PathConfigs
+	// Loaded is the time this config was loaded; used as sitemap lastmod.
+	Loaded time.Time
+	// History tracks when each path was added and last changed.
+	History map[string]*PathHistory
+	// routes are fixed paths served before any configured path.
+	routes map[string]http.HandlerFunc
+	// previews is nil if preview images are disabled.
+	previews *previews
+	// docs caches documentation rendered from local source checkouts.
+	docs *docCache
+	// stats counts requests for each import path.
+	stats *Stats
+	// clock is used for scheduled settings. Shared with the config and every path.
+	clock *clock
+	// aliases maps lowercase paths and aliases to configured paths.
+	aliases map[string]string
 }
 
 // PathConfigs contains our list of configured routing-paths.
@@ -61,15 +99,43 @@ type PathConfig struct {
 		Title string `yaml:"title,omitempty"`
 		URL   string `yaml:"url,omitempty"`
 	} `yaml:"links,omitempty"`
-	Description  string   `yaml:"description,omitempty"`
-	RedirPaths   []string `yaml:"redir_paths,omitempty"`
-	Repo         string   `yaml:"repo,omitempty"`
-	Redir        string   `yaml:"redir,omitempty"`
-	Display      string   `yaml:"display,omitempty"`
-	VCS          string   `yaml:"vcs,omitempty"`
-	Wildcard     bool     `yaml:"wildcard,omitempty"`
-	Name         string   `yaml:"name,omitempty"` // if set, treated as an application
-	cacheControl string
+	Description   string          `yaml:"description,omitempty"`
+	RedirPaths    []string        `yaml:"redir_paths,omitempty"`
+	RedirMatch    string          `yaml:"redir_match,omitempty"` // contains, prefix, segment, suffix or glob.
+	Redirects     []*RedirectRule `yaml:"redirects,omitempty"`
+	Repo          string          `yaml:"repo,omitempty"`
+	Redir         string          `yaml:"redir,omitempty"`
+	Display       string          `yaml:"display,omitempty"`
+	VCS           string          `yaml:"vcs,omitempty"`
+	Wildcard      bool            `yaml:"wildcard,omitempty"`
+	WildcardDepth int             `yaml:"wildcard_depth,omitempty"` // path segments in a wildcard repo name.
+	RepoTemplate  string          `yaml:"repo_template,omitempty"`  // wildcard repo URL with {name} placeholders.
+	Allow         []string        `yaml:"allow,omitempty"`          // wildcard repo names or globs that are served.
+	Deny          []string        `yaml:"deny,omitempty"`           // wildcard repo names or globs that are not served.
+	AllowFile     string          `yaml:"allow_file,omitempty"`     // more allow names, one per line.
+	Unlisted      bool            `yaml:"unlisted,omitempty"`       // hides the path from the index and sitemap.
+	KnownRepos    []string        `yaml:"known_repos,omitempty"`    // listed on a wildcard's namespace page.
+	Name          string          `yaml:"name,omitempty"`           // if set, treated as an application
+	License       string          `yaml:"license,omitempty"`        // SPDX identifier, used in structured data.
+	Social        *SocialConfig   `yaml:"social,omitempty"`
+	DocsURL       string          `yaml:"docs_url,omitempty"`     // template for the documentation link.
+	SourceDir     string          `yaml:"source_dir,omitempty"`   // local checkout used to render docs.
+	Deprecated    bool            `yaml:"deprecated,omitempty"`   // shows a banner; go-get is unchanged.
+	ReplacedBy    string          `yaml:"replaced_by,omitempty"`  // import path of the replacement; implies deprecated.
+	Gone          bool            `yaml:"gone,omitempty"`         // removed on purpose; returns 410.
+	GoneMessage   string          `yaml:"gone_message,omitempty"` // explains why the path is gone.
+	MovedTo       string          `yaml:"moved_to,omitempty"`     // browsers are redirected here; go-get is unchanged.
+	MovedAt       time.Time       `yaml:"moved_at,omitempty"`     // when moved_to starts. Immediately if empty.
+	PublishAt     time.Time       `yaml:"publish_at,omitempty"`   // the path is not served before this time.
+	ExpireAt      time.Time       `yaml:"expire_at,omitempty"`    // the path is not served after this time.
+	Notices       []*Notice       `yaml:"notices,omitempty"`      // banners on this path's package page.
+	Aliases       []string        `yaml:"aliases,omitempty"`      // other paths that redirect here.
+	Profile       string          `yaml:"profile,omitempty"`      // named defaults to inherit from.
+	cacheControl  string
+	docsSource    bool             // use DocsURL as the go-source home.
+	redirGlobs    []*regexp.Regexp // compiled redir_paths, with redir_match: glob.
+	names         *nameFilter      // nil if every wildcard name is allowed.
+	clock         *clock
 }
 
 // vcsPrefixMap provides defaults for VCS type if it's not provided.
@@ -96,18 +162,54 @@ type PathReq struct {
 	Subpath    string
 	IndexTitle string
 	LogoURL    string
+	Previews   bool // preview images are enabled.
+	Badges     bool // built-in badges are enabled.
+	// SiteNotices are the global notices, displayed with the path's notices.
+	SiteNotices []*Notice
+	// importPrefix replaces Path in the import path when go-get requests an alias.
+	importPrefix string
 	*PathConfig
 }
 
 func New(c *Config) (*Handler, error) {
-	h := &Handler{Config: c}
+	h := &Handler{
+		Config: c,
+		Loaded: time.Now().UTC(),
+		docs:   &docCache{pages: make(map[string]*docCached)},
+		clock:  &clock{now: time.Now},
+	}
 
 	if c.Host == "" {
 		return nil, ErrNoHostValue
 	}
 
+	if c.Social.Card == "" {
+		c.Social.Card = defaultCard
+	}
+
+	c.Badges.setDefaults()
+	c.clock = h.clock
+
+	if err := setRedirects(c.Redirects); err != nil {
+		return nil, err
+	}
+
+	prints := make(map[string]string)
+
 	for p := range h.Paths {
+		if h.Paths[p] == nil {
+			// A path with no values, that only uses defaults.
+			h.Paths[p] = &PathConfig{}
+		}
+
 		h.Paths[p].Path = p
+		h.Paths[p].clock = h.clock
+
+		if err := h.Paths[p].setDefaults(c); err != nil {
+			return nil, err
+		}
+
+		prints[p] = h.Paths[p].fingerprint()
 
 		if len(h.Paths[p].RedirPaths) < 1 {
 			// was not provided, pass in global value.
@@ -115,6 +217,25 @@ func New(c *Config) (*Handler, error) {
 		}
 
 		h.Paths[p].setRepoCacheControl(h.CacheAge)
+		h.Paths[p].setSocial(&h.Social)
+		h.Paths[p].setDocsURL(h.DocsURL, h.DocsSource)
+		h.Paths[p].setDeprecated()
+
+		if err := h.Paths[p].setWildcard(); err != nil {
+			return nil, err
+		}
+
+		if err := h.Paths[p].setNameFilter(); err != nil {
+			return nil, err
+		}
+
+		if err := h.Paths[p].setRedirMatch(h.RedirMatch); err != nil {
+			return nil, err
+		}
+
+		if err := setRedirects(h.Paths[p].Redirects); err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
 
 		if err := h.Paths[p].setRepoVCS(); err != nil {
 			return nil, err
@@ -125,6 +246,40 @@ func New(c *Config) (*Handler, error) {
 
 	sort.Sort(h.PathConfigs)
 
+	if err := h.setAliases(); err != nil {
+		return nil, err
+	}
+
+	if err := h.loadHistory(prints); err != nil {
+		return nil, err
+	}
+
+	if err := h.loadStats(); err != nil {
+		return nil, err
+	}
+
+	if !h.Preview.Disable {
+		var err error
+		if h.previews, err = newPreviews(&h.Preview, h.LogoURL); err != nil {
+			return nil, err
+		}
+	}
+
+	h.routes = map[string]http.HandlerFunc{
+		"/sitemap.xml": h.Sitemap,
+		"/robots.txt":  h.RobotsTxt,
+	}
+
+	if h.FeedPath != "" {
+		h.routes[h.FeedPath] = h.Feed
+	}
+
+	if h.Stats.Path != "" {
+		h.routes[h.Stats.Path] = h.StatsPage
+	}
+
+	h.setAPIRoutes()
+
 	return h, nil
 }
 
@@ -144,8 +299,8 @@ func (p *PathConfig) setRepoCacheControl(globalCC *uint64) {
 func (p *PathConfig) setRepoVCS() error {
 	// Check and set VCS type.
 	switch {
-	case p.Repo == "" && p.Redir != "":
-		// Redirect-only can go anywhere.
+	case p.Repo == "" && (p.Redir != "" || p.Gone):
+		// Redirect-only can go anywhere. Gone paths may not have a repo anymore.
 	case p.VCS == "github" || p.VCS == "gitlab" || p.VCS == "bitbucket":
 		p.VCS = "git"
 	case p.VCS == "":
@@ -177,22 +332,27 @@ func findRepoVCS(repo string) (string, error) {
 	return "", fmt.Errorf("%w: %s", ErrUnknownVCS, repo)
 }
 
-// NotFound redirects 404 requests if a redirect URL is set.
-func (h *Handler) NotFound(w http.ResponseWriter, r *http.Request) {
-	if h.Redir404 != "" {
-		http.Redirect(w, r, h.Redir404, http.StatusFound)
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:cyclop
+	if route, ok := h.routes[r.URL.Path]; ok {
+		route(w, r)
+		return
+	}
 
+	pc, canonical := h.find(r)
+	if canonical != "" {
+		// Alias, letter case or trailing slash; send browsers to the canonical path.
+		http.Redirect(w, r, canonical, http.StatusMovedPermanently)
 		return
 	}
 
-	http.NotFound(w, r)
-}
+	if h.serveRedirect(w, r, &pc) {
+		return
+	}
 
-func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:cyclop
-	switch pc := h.PathConfigs.Find(r.URL.Path); {
+	switch {
 	case pc.PathConfig == nil && r.URL.Path != "/":
-		// Unknown URI
-		h.NotFound(w, r)
+		// Unknown URI, but it may be a prefix for nested paths.
+		h.serveNamespace(w, r, &pc)
 	case pc.PathConfig == nil && h.RedirIndex != "":
 		// Index page, but redirect is present.
 		http.Redirect(w, r, h.RedirIndex, http.StatusFound)
@@ -201,6 +361,12 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:c
 		if err := templates.Index.Execute(w, &h.Config); err != nil {
 			http.Error(w, "cannot render the page", http.StatusInternalServerError)
 		}
+	case pc.Gone:
+		// Removed on purpose.
+		h.serveGone(w, r, &pc)
+	case pc.moved(h.clock.Now()) && r.URL.Query().Get("go-get") != "1":
+		// Moved; browsers go to the new home.
+		h.serveMoved(w, r, &pc)
 	case pc.RedirectablePath():
 		// Redirect for file downloads.
 		redirTo := pc.Redir + strings.TrimPrefix(r.URL.Path, pc.Path)
@@ -208,12 +374,36 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:c
 	case pc.Repo == "":
 		// Repo is not set and no paths to redirect, so we're done.
 		h.NotFound(w, r)
+	case pc.Wildcard && pc.Subpath == "" && r.URL.Query().Get("go-get") != "1":
+		// Wildcard prefix without a repo name; list what lives here.
+		h.serveNamespace(w, r, &pc)
+	case pc.Wildcard && pc.Subpath != "" && !pc.hasWildcardName():
+		// Not enough segments for wildcard_depth.
+		h.NotFound(w, r)
+	case pc.Wildcard && pc.Subpath != "" && !pc.allowedName():
+		// Denied, or not on the allowlist.
+		h.NotFound(w, r)
+	case pc.SourceDir != "" && isDocPath(pc.Subpath):
+		// Built-in documentation from a local checkout.
+		pc.Host = h.Host
+		h.serveDocs(w, r, &pc)
+	case !h.Badges.Disable && isBadgePath(pc.Subpath):
+		// Built-in SVG badges.
+		pc.Host = h.Host
+		h.serveBadge(w, r, &pc)
+	case h.previews != nil && previewFormat(pc.Subpath) != "":
+		// Social preview image for a vanity path.
+		pc.Host = h.Host
+		h.previews.servePreview(w, &pc, previewFormat(pc.Subpath))
 	default:
 		// Create a vanity redirect page.
 		w.Header().Set("Cache-Control", pc.cacheControl)
 		pc.Host = h.Host
 		pc.IndexTitle = h.Title
 		pc.LogoURL = h.LogoURL
+		pc.Previews = h.previews != nil
+		pc.Badges = !h.Badges.Disable
+		pc.SiteNotices = h.Notices
 		templ := templates.Vanity
 
 		if r.URL.Query().Get("go-get") == "1" {
@@ -221,13 +411,15 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) { //nolint:c
 			templ = templates.GoGet
 		}
 
+		h.countRequest(r, &pc)
+
 		if err := templ.Execute(w, &pc); err != nil {
 			http.Error(w, "cannot render the page", http.StatusInternalServerError)
 		}
 	}
 }
 
-// RedirectablePath checks if a string exists in a list of strings.
+// RedirectablePath checks if the subpath matches one of the redir_paths.
 // Used to determine if a sub path should be redirected or not.
 // Not used for normal vanity URLs, only used for `redir`.
 func (p *PathReq) RedirectablePath() bool {
@@ -235,8 +427,8 @@ func (p *PathReq) RedirectablePath() bool {
 		return false
 	}
 
-	for _, s := range p.RedirPaths {
-		if strings.Contains(p.Subpath, s) {
+	for i := range p.RedirPaths {
+		if p.redirMatches(i, p.Subpath) {
 			return true
 		}
 	}
@@ -247,9 +439,13 @@ func (p *PathReq) RedirectablePath() bool {
 // ImportPath is used in the template to generate the import path.
 func (p *PathReq) ImportPath() string {
 	path := p.Path
+	if p.importPrefix != "" {
+		path = p.importPrefix
+	}
 
 	if p.Wildcard {
-		path += strings.Split(p.Subpath, "/")[0]
+		name, _, _ := p.WildcardName()
+		path += name
 	}
 
 	return strings.TrimSuffix(path, "/")
@@ -257,13 +453,16 @@ func (p *PathReq) ImportPath() string {
 
 // RepoPath is used in the template to generate the repo path.
 func (p *PathReq) RepoPath() string {
-	repo := p.Repo
+	if !p.Wildcard {
+		return p.Repo
+	}
 
-	if p.Wildcard {
-		repo += strings.Split(p.Subpath, "/")[0]
+	name, _, _ := p.WildcardName()
+	if p.RepoTemplate != "" {
+		return p.templateRepo(name)
 	}
 
-	return repo
+	return p.Repo + name
 }
 
 // Title is used in the template to generate the package title (name).
@@ -287,9 +486,14 @@ func (p *PathReq) SourcePath() string {
 
 	path := p.ImportPath()
 	repo := p.RepoPath()
+	home := repo
+
+	if p.docsSource {
+		home = p.DocsLink()
+	}
 
 	// github, gitlab, git, svn, hg, bzr - may need more tweaking for some of these.
-	return fmt.Sprintf(template, p.Host, path, repo, repo, repo)
+	return fmt.Sprintf(template, p.Host, path, home, repo, repo)
 }
 
 // Len is a sort.Sort interface method.
@@ -17,11 +17,18 @@ package handler_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"golift.io/turbovanityurls/pkg/handler"
 	yaml "gopkg.in/yaml.v3"
@@ -470,3 +477,1194 @@ func TestCacheHeader(t *testing.T) {
 		}
 	}
 }
+
+func TestNamespace(t *testing.T) {
+	t.Parallel()
+
+	config := "host: example.com\n" +
+		"paths:\n" +
+		"  /org/a:\n" +
+		"    repo: https://github.com/org/a\n" +
+		"  /org/b:\n" +
+		"    repo: https://github.com/org/b\n" +
+		"  /david/:\n" +
+		"    repo: https://github.com/davidnewhall/\n" +
+		"    wildcard: true\n" +
+		"    known_repos: [secspy, motifini]\n"
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   []string
+	}{
+		{
+			name:   "nested children",
+			path:   "/org/",
+			status: http.StatusOK,
+			want:   []string{`href="/org/a"`, `href="/org/b"`},
+		},
+		{
+			name:   "nested children without slash",
+			path:   "/org",
+			status: http.StatusOK,
+			want:   []string{`href="/org/a"`, `href="/org/b"`},
+		},
+		{
+			name:   "wildcard known repos",
+			path:   "/david/",
+			status: http.StatusOK,
+			want:   []string{`href="/david/secspy"`, `href="/david/motifini"`},
+		},
+		{
+			name:   "no children",
+			path:   "/nothing/",
+			status: http.StatusNotFound,
+		},
+	}
+
+	h, err := handler.New(getTestConfig([]byte(config)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	for _, test := range tests {
+		resp, err := http.Get(s.URL + test.path)
+		if err != nil {
+			t.Errorf("%s: http.Get: %v", test.name, err)
+			continue
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status code = %s; want %d", test.name, resp.Status, test.status)
+		}
+
+		for _, want := range test.want {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("%s: page is missing %q", test.name, want)
+			}
+		}
+
+		if got := findMeta(data, "go-import"); got != "" {
+			t.Errorf("%s: namespace page must not have go-import meta: %q", test.name, got)
+		}
+	}
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n" +
+		"  /starr:\n    repo: https://github.com/golift/starr\n" +
+		"  /cnfg:\n    repo: https://github.com/golift/cnfg\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "/unfi", want: []string{"/unifi"}},
+		{query: "/Starr", want: []string{"/starr"}},
+		{query: "/STARR/subpkg", want: []string{"/starr"}},
+		{query: "/xyzzy-nothing-close", want: nil},
+	}
+
+	for _, test := range tests {
+		got := h.PathConfigs.Suggest(test.query)
+		if len(got) != len(test.want) {
+			t.Errorf("Suggest(%q) returned %d paths; want %d", test.query, len(got), len(test.want))
+			continue
+		}
+
+		for i := range got {
+			if got[i].Path != test.want[i] {
+				t.Errorf("Suggest(%q)[%d] = %q; want %q", test.query, i, got[i].Path, test.want[i])
+			}
+		}
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/unfi")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status code = %s; want 404", resp.Status)
+	}
+
+	if !bytes.Contains(data, []byte(`href="/unifi"`)) {
+		t.Errorf("404 page is missing a suggestion for /unifi")
+	}
+
+	// The request path is displayed on the 404 page, so it must be escaped.
+	resp, err = http.Get(s.URL + "/%3Cscript%3Ealert(1)%3C/script%3E")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if bytes.Contains(data, []byte("<script>")) || !bytes.Contains(data, []byte("&lt;script&gt;")) {
+		t.Errorf("404 page must escape the request path:\n%s", data)
+	}
+}
+
+func TestSitemapRobots(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"robots:\n  hide_unlisted: true\n  hide_redirects: true\n  disallow: [/private]\n" +
+		"paths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n" +
+		"  /hidden:\n    repo: https://github.com/golift/hidden\n    unlisted: true\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n" +
+		"  /source:\n    redir: https://github.com/golift/turbovanityurls\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return string(data)
+	}
+
+	sitemap := get("/sitemap.xml")
+	for _, want := range []string{"<loc>https://example.com/</loc>", "<loc>https://example.com/unifi</loc>"} {
+		if !strings.Contains(sitemap, want) {
+			t.Errorf("sitemap is missing %q:\n%s", want, sitemap)
+		}
+	}
+
+	for _, unwanted := range []string{"/hidden", "/david", "/source"} {
+		if strings.Contains(sitemap, unwanted) {
+			t.Errorf("sitemap must not contain %q:\n%s", unwanted, sitemap)
+		}
+	}
+
+	robots := get("/robots.txt")
+	for _, want := range []string{
+		"Disallow: /private\n", "Disallow: /hidden\n", "Disallow: /source\n",
+		"Sitemap: https://example.com/sitemap.xml\n",
+	} {
+		if !strings.Contains(robots, want) {
+			t.Errorf("robots.txt is missing %q:\n%s", want, robots)
+		}
+	}
+}
+
+func TestFeedHistory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	config := "host: example.com\nfeed_path: /feed.atom\nstate_dir: " + dir + "\npaths:\n" +
+		"  /a:\n    repo: https://github.com/golift/a\n    description: first\n" +
+		"  /b:\n    repo: https://github.com/golift/b\n"
+
+	first, err := handler.New(getTestConfig([]byte(config)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte(strings.Replace(config, "first", "second", 1))))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if a := h.History["/a"]; a == nil || !a.Updated.After(a.Added) || !a.Added.Equal(first.Loaded) {
+		t.Errorf("changed path /a must keep its added time and get a new updated time: %+v", a)
+	}
+
+	if b := h.History["/b"]; b == nil || !b.Updated.Equal(first.Loaded) {
+		t.Errorf("unchanged path /b must keep its updated time: %+v", b)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/feed.atom")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	for _, want := range []string{"<title>Updated example.com/a</title>", "<title>Added example.com/b</title>", "second"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("feed is missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestSocialMeta(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\ntitle: Example\nlogo_url: /logo.png\n" +
+		"social:\n  twitter_site: \"@example\"\n" +
+		"paths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n    license: MIT\n" +
+		"    description: UniFi <b>library</b>\n" +
+		"  /quiet:\n    repo: https://github.com/golift/quiet\n    social:\n      disable: true\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	get := func(path string) []byte {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return data
+	}
+
+	page := get("/unifi")
+	for _, want := range []string{
+		`<meta property="og:title" content="example.com/unifi">`,
+		`<meta property="og:description" content="UniFi &lt;b&gt;library&lt;/b&gt;">`,
+		`<meta property="og:image" content="https://example.com/unifi/preview.png">`,
+		`<meta name="twitter:card" content="summary">`,
+		`<meta name="twitter:site" content="@example">`,
+		`"@type":"SoftwareSourceCode"`,
+		`"codeRepository":"https://github.com/golift/unifi"`,
+		`"license":"MIT"`,
+	} {
+		if !bytes.Contains(page, []byte(want)) {
+			t.Errorf("vanity page is missing %q", want)
+		}
+	}
+
+	if page = get("/quiet"); bytes.Contains(page, []byte("og:title")) {
+		t.Errorf("social metadata must not render when disabled")
+	}
+
+	if page = get("/"); !bytes.Contains(page, []byte(`<meta property="og:title" content="Example">`)) {
+		t.Errorf("index page is missing og:title")
+	}
+}
+
+func TestPreviewImages(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"preview:\n  background: \"#000000\"\n" +
+		"paths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n    description: A <b>UniFi</b> & library.\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n    known_repos: [secspy]\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	tests := []struct {
+		path        string
+		contentType string
+		want        string
+		reject      string
+	}{
+		{path: "/unifi/preview.png", contentType: "image/png", want: "\x89PNG"},
+		{path: "/unifi/preview.svg", contentType: "image/svg+xml", want: "A UniFi &amp; library."},
+		{path: "/david/secspy/preview.svg", contentType: "image/svg+xml", want: "example.com/david/secspy"},
+		// Names that are not known repos get the wildcard path's image.
+		{path: "/david/random/preview.svg", contentType: "image/svg+xml", want: "example.com/david<", reject: "random"},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(s.URL + test.path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if got := resp.Header.Get("Content-Type"); got != test.contentType {
+			t.Errorf("%s: Content-Type = %q; want %q", test.path, got, test.contentType)
+		}
+
+		if !bytes.Contains(data, []byte(test.want)) {
+			t.Errorf("%s: image is missing %q", test.path, test.want)
+		}
+
+		if test.reject != "" && bytes.Contains(data, []byte(test.reject)) {
+			t.Errorf("%s: image must not contain %q", test.path, test.reject)
+		}
+	}
+
+	_, err = handler.New(getTestConfig([]byte("host: example.com\npreview:\n  foreground: blue\n")))
+	if !errors.Is(err, handler.ErrInvalidColor) {
+		t.Errorf("an invalid preview color must return an error, got: %v", err)
+	}
+}
+
+func TestDocsURL(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"docs_url: https://docs.internal/{host}{path}\ndocs_source: true\n" +
+		"paths:\n" +
+		"  /default:\n    repo: https://github.com/golift/default\n" +
+		"  /custom:\n    repo: https://github.com/golift/custom\n    docs_url: https://custom/{import}\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	tests := []struct {
+		path     string
+		docs     string
+		goSource string
+	}{
+		{
+			path: "/default",
+			docs: `<form action="https://docs.internal/example.com/default"`,
+			goSource: "example.com/default https://docs.internal/example.com/default " +
+				"https://github.com/golift/default/tree/master{/dir} " +
+				"https://github.com/golift/default/blob/master{/dir}/{file}#L{line}",
+		},
+		{
+			path: "/custom",
+			docs: `<form action="https://custom/example.com/custom"`,
+			goSource: "example.com/custom https://custom/example.com/custom " +
+				"https://github.com/golift/custom/tree/master{/dir} " +
+				"https://github.com/golift/custom/blob/master{/dir}/{file}#L{line}",
+		},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(s.URL + test.path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if !bytes.Contains(data, []byte(test.docs)) {
+			t.Errorf("%s: page is missing docs link %q", test.path, test.docs)
+		}
+
+		if got := findMeta(data, "go-source"); got != test.goSource {
+			t.Errorf("%s: meta go-source = %q; want %q", test.path, got, test.goSource)
+		}
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.go":      "// Package lib does things.\npackage lib\n\n// Hello says <hello>.\nfunc Hello() string { return \"hi\" }\n",
+		"lib_test.go": "package lib_test\n\nimport \"fmt\"\n\nfunc ExampleHello() {\n\tfmt.Println(\"hi\")\n\t// Output: hi\n}\n",
+		"sub/sub.go":  "// Package sub is nested.\npackage sub\n",
+	}
+
+	for name, content := range files {
+		_ = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("writing test source: %v", err)
+		}
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /lib:\n    repo: https://github.com/golift/lib\n    source_dir: " + dir + "\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Serve through a mux, like the service does, so paths are not cleaned first.
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		want   []string
+	}{
+		{
+			path:   "/lib",
+			status: http.StatusOK,
+			want:   []string{`href = 'https://example.com/lib/-/doc'`},
+		},
+		{
+			path:   "/lib/-/doc",
+			status: http.StatusOK,
+			want: []string{
+				"<h1>package lib</h1>", `import "example.com/lib"`, "Package lib does things.",
+				"func Hello() string", "Hello says &lt;hello&gt;.", "<p>Output:</p>", `href="/lib/-/doc/sub"`,
+			},
+		},
+		{
+			path:   "/lib/-/doc/sub",
+			status: http.StatusOK,
+			want:   []string{"<h1>package sub</h1>", `import "example.com/lib/sub"`},
+		},
+		{
+			path:   "/lib/-/doc/missing",
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/lib/-/doc/%3Cimg%20src%3Dx%20onerror%3Dalert(1)%3E%2F..",
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/lib/-/doc/sub%2F..%2Fsub",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(s.URL + test.path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%s: status code = %s; want %d", test.path, resp.Status, test.status)
+		}
+
+		for _, want := range test.want {
+			if !bytes.Contains(data, []byte(want)) {
+				t.Errorf("%s: page is missing %q", test.path, want)
+			}
+		}
+
+		if bytes.Contains(data, []byte("<img")) {
+			t.Errorf("%s: page has an unescaped tag from the path", test.path)
+		}
+	}
+}
+
+func TestBadges(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"badges:\n  color: \"#123456\"\n  fetches_label: downloads\n" +
+		"paths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return resp.StatusCode, string(data)
+	}
+
+	get("/unifi/sub?go-get=1")
+	get("/unifi?go-get=1")
+
+	tests := []struct {
+		path   string
+		status int
+		want   []string
+	}{
+		{path: "/unifi/-/badge/import.svg", status: http.StatusOK, want: []string{"import: example.com/unifi", `fill="#123456"`}},
+		{path: "/unifi/-/badge/reference.svg", status: http.StatusOK, want: []string{"go: reference"}},
+		{path: "/unifi/-/badge/fetches.svg", status: http.StatusOK, want: []string{"downloads: 2"}},
+		{path: "/david/secspy/-/badge/import.svg", status: http.StatusOK, want: []string{"example.com/david/secspy"}},
+		{path: "/unifi/-/badge/nope.svg", status: http.StatusNotFound},
+		{path: "/unifi", status: http.StatusOK, want: []string{"(https://pkg.go.dev/example.com/unifi)"}},
+	}
+
+	for _, test := range tests {
+		status, body := get(test.path)
+		if status != test.status {
+			t.Errorf("%s: status code = %d; want %d", test.path, status, test.status)
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: badge is missing %q:\n%s", test.path, want, body)
+			}
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\nstats:\n  path: /stats\n"))); !errors.Is(err, handler.ErrStatsNoAuth) {
+		t.Errorf("stats page without a password must return ErrStatsNoAuth, got: %v", err)
+	}
+
+	dir := t.TempDir()
+	config := getTestConfig([]byte("host: example.com\nstate_dir: " + dir + "\napi_path: /api\n" +
+		"stats:\n  path: /stats\n  username: admin\n  password: secret\n" +
+		"paths:\n  /unifi:\n    repo: https://github.com/golift/unifi\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n    known_repos: [secspy]\n"))
+
+	h, err := handler.New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	get := func(path string, auth bool) (int, string) {
+		req, _ := http.NewRequest(http.MethodGet, s.URL+path, nil)
+		if auth {
+			req.SetBasicAuth("admin", "secret")
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("http.Do: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return resp.StatusCode, string(data)
+	}
+
+	get("/unifi?go-get=1", false)
+	get("/unifi/v2/pkg?go-get=1", false)
+	get("/unifi", false)
+	// Wildcard names that are not known repos are counted under the wildcard path.
+	get("/david/secspy?go-get=1", false)
+	get("/david/random?go-get=1", false)
+	get("/david/other/v100?go-get=1", false)
+
+	if status, _ := get("/stats", false); status != http.StatusUnauthorized {
+		t.Errorf("stats page without auth must return 401, got %d", status)
+	}
+
+	if status, _ := get("/api/stats", false); status != http.StatusUnauthorized {
+		t.Errorf("stats api without auth must return 401, got %d", status)
+	}
+
+	if status, body := get("/stats", true); status != http.StatusOK || !strings.Contains(body, "go-get/v2: 1") {
+		t.Errorf("stats page must show counters, got %d:\n%s", status, body)
+	}
+
+	want := `[{"importPath":"/david","goGet":2,"browser":0,"counters":{"go-get/v1":2}},` +
+		`{"importPath":"/david/secspy","goGet":1,"browser":0,"counters":{"go-get/v1":1}},` +
+		`{"importPath":"/unifi","goGet":2,"browser":1,"counters":{"browser/v1":1,"go-get/v1":1,"go-get/v2":1}}]`
+	if _, body := get("/api/stats", true); strings.TrimSpace(body) != want {
+		t.Errorf("stats api:\n got: %s\nwant: %s", body, want)
+	}
+
+	if _, body := get("/api/paths", false); !strings.Contains(body, `"path":"/unifi","importPath":"example.com/unifi"`) {
+		t.Errorf("paths api is missing /unifi: %s", body)
+	}
+
+	if err := h.SaveStats(); err != nil {
+		t.Fatalf("SaveStats: %v", err)
+	}
+
+	// Counters are loaded from state_dir on the next start.
+	h, err = handler.New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	s2 := httptest.NewServer(h)
+	defer s2.Close()
+
+	resp, err := http.Get(s2.URL + "/unifi/-/badge/fetches.svg")
+	if err != nil {
+		t.Fatalf("http.Get: %v", err)
+	}
+
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if !bytes.Contains(data, []byte("go get: 2")) {
+		t.Errorf("fetches badge must use saved stats:\n%s", data)
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\nredirects:\n" +
+		"  - match: /a\n    target: /b\n    status: 200\n"))); !errors.Is(err, handler.ErrInvalidRedirect) {
+		t.Errorf("status 200 must return ErrInvalidRedirect, got: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"redirects:\n" +
+		"  - match: /old/\n    target: /new/$1\n    status: 301\n" +
+		"  - match: /blog/*/*.html\n    type: glob\n    target: https://blog.example.com/$1/$2\n    keep_query: true\n" +
+		"  - match: ^/r/(?P<name>[a-z]+)$\n    type: regex\n    target: https://github.com/golift/${name}\n    status: 308\n" +
+		"paths:\n  /unifi:\n    repo: https://github.com/golift/unifi\n    redirects:\n" +
+		"      - match: releases/**\n        type: glob\n        target: https://github.com/golift/unifi/releases/$1\n" +
+		"        status: 307\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/old/thing", status: http.StatusMovedPermanently, location: "/new/thing"},
+		{path: "/blog/2024/post.html?utm=x", status: http.StatusFound, location: "https://blog.example.com/2024/post?utm=x"},
+		{path: "/blog/2024/06/post.html", status: http.StatusNotFound},
+		{path: "/r/unifi", status: http.StatusPermanentRedirect, location: "https://github.com/golift/unifi"},
+		{path: "/unifi/releases/tag/v1.0.0", status: http.StatusTemporaryRedirect,
+			location: "https://github.com/golift/unifi/releases/tag/v1.0.0"},
+		{path: "/unifi/releases/tag?go-get=1", status: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: status code = %d; want %d", test.path, w.Code, test.status)
+		}
+
+		if loc := w.Header().Get("Location"); loc != test.location {
+			t.Errorf("%s: location = %q; want %q", test.path, loc, test.location)
+		}
+	}
+}
+
+func TestRedirMatch(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\nredir_match: nope\n" +
+		"paths:\n  /a:\n    redir: https://example.org\n"))); !errors.Is(err, handler.ErrRedirMatch) {
+		t.Errorf("unknown redir_match must return ErrRedirMatch, got: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\nredir_paths: [wiki]\npaths:\n" +
+		"  /contains:\n    repo: https://github.com/golift/a\n    redir: https://example.org/a\n" +
+		"  /segment:\n    repo: https://github.com/golift/b\n    redir: https://example.org/b\n    redir_match: segment\n" +
+		"  /prefix:\n    repo: https://github.com/golift/c\n    redir: https://example.org/c\n    redir_match: prefix\n" +
+		"    redir_paths: [releases, releases/download]\n" +
+		"  /suffix:\n    repo: https://github.com/golift/d\n    redir: https://example.org/d\n    redir_match: suffix\n" +
+		"    redir_paths: [.tar.gz]\n" +
+		"  /glob:\n    repo: https://github.com/golift/\n    redir: https://example.org/e\n    redir_match: glob\n" +
+		"    wildcard: true\n    redir_paths: [\"*/wiki/**\"]\n    known_repos: [app, \"x/wiki/y\"]\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		redir bool
+	}{
+		{path: "/contains/pkg/wikiparser", redir: true},
+		{path: "/segment/pkg/wikiparser", redir: false},
+		{path: "/segment/pkg/wiki", redir: true},
+		{path: "/prefix/releases/v1", redir: true},
+		{path: "/prefix/pkg/releases", redir: false},
+		{path: "/suffix/v1.tar.gz", redir: true},
+		{path: "/suffix/v1.tar.gz/pkg", redir: false},
+		{path: "/glob/app/wiki/Home", redir: true},
+		{path: "/glob/app/pkg/wiki", redir: false},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if redir := w.Code == http.StatusFound; redir != test.redir {
+			t.Errorf("%s: redirected = %v; want %v (status %d)", test.path, redir, test.redir, w.Code)
+		}
+	}
+
+	warnings := strings.Join(h.Warnings(), "\n")
+	for _, want := range []string{`/prefix: redir_paths "releases" overlaps "releases/download"`, `shadows known repo "x/wiki/y"`} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("warnings are missing %q:\n%s", want, warnings)
+		}
+	}
+
+	if strings.Count(warnings, "\n") != 1 {
+		t.Errorf("expected exactly 2 warnings:\n%s", warnings)
+	}
+}
+
+func TestWildcardTemplates(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /a:\n    repo: https://github.com/golift/a\n    repo_template: https://github.com/x/{name}\n"))); !errors.Is(err, handler.ErrRepoTemplate) {
+		t.Errorf("repo_template without wildcard must return ErrRepoTemplate, got: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /captain-:\n    wildcard: true\n    repo_template: https://github.com/x/{matched_prefix}{name}\n" +
+		"  /team/:\n    wildcard: true\n    wildcard_depth: 2\n" +
+		"    repo_template: https://gitlab.com/team/{segment1}/{segment2}\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{path: "/captain-hook/pkg?go-get=1", status: http.StatusOK,
+			want: `content="example.com/captain-hook git https://github.com/x/captain-hook"`},
+		{path: "/team/group/project/pkg?go-get=1", status: http.StatusOK,
+			want: `content="example.com/team/group/project git https://gitlab.com/team/group/project"`},
+		{path: "/team/group?go-get=1", status: http.StatusNotFound},
+		{path: "/team/group/project/-/badge/import.svg", status: http.StatusOK, want: "example.com/team/group/project"},
+		{path: "/team/group/-/badge/import.svg", status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: status code = %d; want %d", test.path, w.Code, test.status)
+		}
+
+		if !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("%s: body is missing %q:\n%s", test.path, test.want, w.Body.String())
+		}
+	}
+}
+
+func TestWildcardAllowDeny(t *testing.T) {
+	t.Parallel()
+
+	allowFile := filepath.Join(t.TempDir(), "allow.txt")
+	if err := os.WriteFile(allowFile, []byte("# repos\nsecspy\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n" +
+		"    allow: [unifi, \"go-*\"]\n    deny: [go-bad]\n    allow_file: " + allowFile + "\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	status := func(path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w.Code
+	}
+
+	for path, want := range map[string]int{
+		"/david/unifi?go-get=1":        http.StatusOK,
+		"/david/go-thing/pkg?go-get=1": http.StatusOK,
+		"/david/go-bad?go-get=1":       http.StatusNotFound,
+		"/david/secspy":                http.StatusOK,
+		"/david/typo?go-get=1":         http.StatusNotFound,
+		"/david/motifini":              http.StatusNotFound,
+	} {
+		if got := status(path); got != want {
+			t.Errorf("%s: status code = %d; want %d", path, got, want)
+		}
+	}
+
+	// The allow file is read again when it changes.
+	if err := os.WriteFile(allowFile, []byte("secspy\nmotifini\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if got := status("/david/motifini"); got != http.StatusOK {
+		t.Errorf("names added to the allow file must be served after it changes, got %d", got)
+	}
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /a:\n    repo: https://github.com/golift/a\n    deny: [x]\n"))); !errors.Is(err, handler.ErrRepoTemplate) {
+		t.Errorf("deny without wildcard must return ErrRepoTemplate, got: %v", err)
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	t.Parallel()
+
+	config := "host: example.com\napi_path: /api\npaths:\n" +
+		"  /old:\n    repo: https://github.com/golift/old\n    replaced_by: example.com/new\n" +
+		"  /new:\n    repo: https://github.com/golift/new\n"
+
+	h, err := handler.New(getTestConfig([]byte(config)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	get := func(h *handler.Handler, path string) string {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w.Body.String()
+	}
+
+	if body := get(h, "/old"); !strings.Contains(body, `Use <a href="https://example.com/new">example.com/new</a> instead.`) {
+		t.Errorf("vanity page is missing the deprecation banner:\n%s", body)
+	}
+
+	if body := get(h, "/old?go-get=1"); strings.Contains(body, "Deprecated") ||
+		!strings.Contains(body, `content="example.com/old git https://github.com/golift/old"`) {
+		t.Errorf("go-get output must not change for deprecated paths:\n%s", body)
+	}
+
+	if body := get(h, "/api/paths"); !strings.Contains(body, `"deprecated":true,"replacedBy":"example.com/new"`) {
+		t.Errorf("paths api is missing deprecation data:\n%s", body)
+	}
+
+	body := get(h, "/")
+	if i, j := strings.Index(body, "<h5>Deprecated</h5>"), strings.Index(body, `<a href="/old">`); i < 0 || j < i {
+		t.Errorf("index page must group deprecated modules:\n%s", body)
+	}
+
+	h, err = handler.New(getTestConfig([]byte("hide_deprecated: true\n" + config)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if body := get(h, "/"); strings.Contains(body, `<a href="/old">`) || !strings.Contains(body, `<a href="/new">`) {
+		t.Errorf("index page must hide deprecated modules:\n%s", body)
+	}
+}
+
+func TestGone(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\nredir_404: https://example.com/\npaths:\n" +
+		"  /removed:\n    gone: true\n    gone_message: Removed at the owner's request.\n" +
+		"  /nested/removed:\n    repo: https://github.com/golift/removed\n    gone: true\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/removed/pkg", want: "<p>Removed at the owner's request.</p>"},
+		{path: "/removed?go-get=1", want: "example.com/removed is gone: Removed at the owner's request.\n"},
+		{path: "/nested/removed?go-get=1", want: "example.com/nested/removed is gone\n"},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != http.StatusGone {
+			t.Errorf("%s: status code = %d; want %d", test.path, w.Code, http.StatusGone)
+		}
+
+		if !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("%s: body is missing %q:\n%s", test.path, test.want, w.Body.String())
+		}
+	}
+
+	// Gone paths are not listed in namespaces, so /nested/ has nothing left to show.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nested/", nil))
+
+	if w.Code != http.StatusFound {
+		t.Errorf("empty namespace must use redir_404, got %d", w.Code)
+	}
+}
+
+func TestMovedTo(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n    moved_to: https://new.example.com/unifi/\n" +
+		"  /later:\n    repo: https://github.com/golift/later\n    moved_to: https://new.example.com/later\n" +
+		"    moved_at: 2030-01-02T00:00:00Z\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	now := time.Date(2029, 12, 31, 0, 0, 0, 0, time.UTC)
+	h.SetClock(func() time.Time { return now })
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w
+	}
+
+	if w := request("/unifi/pkg"); w.Code != http.StatusMovedPermanently ||
+		w.Header().Get("Location") != "https://new.example.com/unifi/pkg" {
+		t.Errorf("browsers must be redirected to the new home, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	if w := request("/unifi/pkg?go-get=1"); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `content="example.com/unifi git https://github.com/golift/unifi"`) {
+		t.Errorf("go-get must keep the old meta tags, got %d:\n%s", w.Code, w.Body.String())
+	}
+
+	if w := request("/later"); w.Code != http.StatusOK {
+		t.Errorf("moves must not start before moved_at, got %d", w.Code)
+	}
+
+	now = now.AddDate(0, 0, 2)
+
+	if w := request("/later"); w.Code != http.StatusMovedPermanently {
+		t.Errorf("moves must start after moved_at, got %d", w.Code)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\n" +
+		"notices:\n  - message: Site maintenance tonight.\n    end: 2030-01-02T00:00:00Z\n" +
+		"redirects:\n  - match: /conf\n    target: https://conf.example.com/\n    expire_at: 2030-01-02T00:00:00Z\n" +
+		"paths:\n" +
+		"  /launch:\n    repo: https://github.com/golift/launch\n    publish_at: 2030-01-02T00:00:00Z\n" +
+		"    notices:\n      - message: Launched!\n        start: 2030-01-02T00:00:00Z\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n" +
+		"  /org:\n    repo: https://github.com/golift/org\n" +
+		"  /org/conf:\n    repo: https://github.com/golift/conf\n    expire_at: 2030-01-02T00:00:00Z\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	h.SetClock(func() time.Time { return now })
+
+	request := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		return w
+	}
+
+	if w := request("/launch?go-get=1"); w.Code != http.StatusNotFound {
+		t.Errorf("unpublished paths must return 404, got %d", w.Code)
+	}
+
+	if w := request("/conf"); w.Code != http.StatusFound {
+		t.Errorf("redirect must work before it expires, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/", "/unifi"} {
+		if w := request(path); !strings.Contains(w.Body.String(), "Site maintenance tonight.") {
+			t.Errorf("%s: site notice is missing:\n%s", path, w.Body.String())
+		}
+	}
+
+	now = now.AddDate(0, 0, 2)
+
+	if w := request("/launch"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Launched!") ||
+		strings.Contains(w.Body.String(), "Site maintenance") {
+		t.Errorf("published path must be served with its notice and without the ended notice, got %d:\n%s",
+			w.Code, w.Body.String())
+	}
+
+	if w := request("/conf"); w.Code != http.StatusNotFound {
+		t.Errorf("expired redirect must return 404, got %d", w.Code)
+	}
+
+	// An expired path is the same as not configured, so its parent path serves it.
+	if w := request("/org/conf?go-get=1"); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `content="example.com/org git https://github.com/golift/org"`) {
+		t.Errorf("expired path must be served by its parent path, got %d:\n%s", w.Code, w.Body.String())
+	}
+}
+
+func TestAliases(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /a:\n    repo: https://github.com/golift/a\n    aliases: [/B]\n" +
+		"  /b:\n    repo: https://github.com/golift/b\n"))); !errors.Is(err, handler.ErrAliasConflict) {
+		t.Errorf("alias matching a configured path must return ErrAliasConflict, got: %v", err)
+	}
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /unpoller:\n    repo: https://github.com/unpoller/unpoller\n    aliases: [/unifi-poller]\n" +
+		"  /kafka:\n    repo: https://github.com/golift/kafka\n" +
+		"  /ⱥbc:\n    repo: https://github.com/golift/abc\n" +
+		"  /david/:\n    repo: https://github.com/davidnewhall/\n    wildcard: true\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+		want     string
+	}{
+		{path: "/unifi-poller/pkg?x=1", status: http.StatusMovedPermanently, location: "/unpoller/pkg?x=1"},
+		{path: "/UnPoller", status: http.StatusMovedPermanently, location: "/unpoller"},
+		{path: "/unpoller/", status: http.StatusMovedPermanently, location: "/unpoller"},
+		{path: "/david", status: http.StatusMovedPermanently, location: "/david/"},
+		{path: "/David/secspy", status: http.StatusMovedPermanently, location: "/david/secspy"},
+		{path: "/unpoller", status: http.StatusOK},
+		// Letter case changes that change the length of the path: a Kelvin sign, and Ⱥ (2 bytes) to ⱥ (3 bytes).
+		{path: "/%E2%84%AAafka/sub", status: http.StatusMovedPermanently, location: "/kafka/sub"},
+		{path: "/%C8%BAbc", status: http.StatusMovedPermanently, location: "/%e2%b1%a5bc"},
+		{path: "/unifi-poller/pkg?go-get=1", status: http.StatusOK,
+			want: `content="example.com/unifi-poller git https://github.com/unpoller/unpoller"`},
+		{path: "/David/secspy?go-get=1", status: http.StatusOK,
+			want: `content="example.com/David/secspy git https://github.com/davidnewhall/secspy"`},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		if w.Code != test.status {
+			t.Errorf("%s: status code = %d; want %d", test.path, w.Code, test.status)
+		}
+
+		if loc := w.Header().Get("Location"); loc != test.location {
+			t.Errorf("%s: location = %q; want %q", test.path, loc, test.location)
+		}
+
+		if !strings.Contains(w.Body.String(), test.want) {
+			t.Errorf("%s: body is missing %q:\n%s", test.path, test.want, w.Body.String())
+		}
+	}
+}
+
+func TestDefaultsProfiles(t *testing.T) {
+	t.Parallel()
+
+	if _, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /a:\n    repo: https://github.com/golift/a\n    profile: nope\n"))); !errors.Is(err, handler.ErrUnknownProfile) {
+		t.Errorf("unknown profile must return ErrUnknownProfile, got: %v", err)
+	}
+
+	config := getTestConfig([]byte("host: example.com\n" +
+		"defaults:\n  repo: https://github.com/golift/{name}\n  redir: \"{repo}\"\n  cache_max_age: 60\n" +
+		"profiles:\n  app:\n    redir: https://github.com/davidnewhall/{name}\n    redir_paths: [\"\"]\n" +
+		"paths:\n  /cnfg:\n  /org/starr:\n    description: \"{host}/{path}\"\n" +
+		"  /secspy:\n    profile: app\n    repo: https://gitlab.com/x/secspy\n"))
+
+	if _, err := handler.New(config); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		path, repo, redir, description string
+	}{
+		{path: "/cnfg", repo: "https://github.com/golift/cnfg", redir: "https://github.com/golift/cnfg"},
+		{path: "/org/starr", repo: "https://github.com/golift/starr", redir: "https://github.com/golift/starr",
+			description: "example.com/org/starr"},
+		{path: "/secspy", repo: "https://gitlab.com/x/secspy", redir: "https://github.com/davidnewhall/secspy"},
+	}
+
+	for _, test := range tests {
+		p := config.Paths[test.path]
+		if p.Repo != test.repo || p.Redir != test.redir || p.Description != test.description {
+			t.Errorf("%s: got repo=%q redir=%q description=%q; want %q %q %q",
+				test.path, p.Repo, p.Redir, p.Description, test.repo, test.redir, test.description)
+		}
+
+		if p.CacheAge == nil || *p.CacheAge != 60 {
+			t.Errorf("%s: cache_max_age must be inherited from defaults", test.path)
+		}
+	}
+
+	if p := config.Paths["/secspy"]; len(p.RedirPaths) != 1 || p.RedirPaths[0] != "" {
+		t.Errorf("redir_paths must be inherited from the profile: %q", p.RedirPaths)
+	}
+}
+
+func TestSuggestLongPath(t *testing.T) {
+	t.Parallel()
+
+	h, err := handler.New(getTestConfig([]byte("host: example.com\npaths:\n" +
+		"  /unifi:\n    repo: https://github.com/golift/unifi\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// A long request path must not be compared character by character with every configured path.
+	start := time.Now()
+	if got := h.PathConfigs.Suggest("/" + strings.Repeat("a", 1<<20)); len(got) != 0 {
+		t.Errorf("Suggest returned %d paths for a long path; want 0", len(got))
+	}
+
+	if got := h.PathConfigs.Suggest("/unifi/" + strings.Repeat("a", 1<<20)); len(got) != 1 {
+		t.Errorf("Suggest returned %d paths for a long subpath; want 1", len(got))
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Suggest took %v for a long path", elapsed)
+	}
+}
+
+func TestHistoryFingerprint(t *testing.T) {
+	t.Parallel()
+
+	config := "host: example.com\npaths:\n  /a:\n    repo: https://github.com/golift/a\n"
+
+	h, err := handler.New(getTestConfig([]byte(config)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Only values that are set are hashed, so a PathConfig field added later does not change this.
+	sum := sha256.Sum256([]byte("repo: https://github.com/golift/a\n"))
+	if fp := h.History["/a"].Fingerprint; fp != hex.EncodeToString(sum[:]) {
+		t.Errorf("fingerprint must only hash the values that are set: %s", fp)
+	}
+
+	zero, err := handler.New(getTestConfig([]byte(config + "    unlisted: false\n    wildcard_depth: 0\n")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if zero.History["/a"].Fingerprint != h.History["/a"].Fingerprint {
+		t.Errorf("zero values must not change the fingerprint")
+	}
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// historyFile is the name of the file in state_dir that tracks path changes.
+const historyFile = "history.json"
+
+// PathHistory tracks when a configured path first appeared and last changed.
+type PathHistory struct {
+	Fingerprint string    `json:"fingerprint"`
+	Added       time.Time `json:"added"`
+	Updated     time.Time `json:"updated"`
+}
+
+// fingerprint returns a hash of a path's configuration as it was provided.
+// This must be called before New() fills in values, like VCS and RedirPaths.
+// Only values that are set are hashed (every yaml tag has omitempty), so new
+// PathConfig fields do not change the fingerprint of paths that don't use them.
+func (p *PathConfig) fingerprint() string {
+	data, _ := yaml.Marshal(p)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// loadHistory reads the history state file (if configured), compares it to the
+// provided fingerprints, and writes it back with the current changes applied.
+func (h *Handler) loadHistory(prints map[string]string) error {
+	history := make(map[string]*PathHistory)
+
+	if h.StateDir != "" {
+		data, err := os.ReadFile(filepath.Join(h.StateDir, historyFile))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("reading state file: %w", err)
+		}
+
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &history); err != nil {
+				return fmt.Errorf("parsing state file: %w", err)
+			}
+		}
+	}
+
+	h.History = make(map[string]*PathHistory)
+
+	for path, fp := range prints {
+		switch hist := history[path]; {
+		case hist == nil:
+			h.History[path] = &PathHistory{Fingerprint: fp, Added: h.Loaded, Updated: h.Loaded}
+		case hist.Fingerprint != fp:
+			hist.Fingerprint = fp
+			hist.Updated = h.Loaded
+
+			fallthrough
+		default:
+			h.History[path] = hist
+		}
+	}
+
+	if h.StateDir == "" {
+		return nil
+	}
+
+	data, _ := json.MarshalIndent(h.History, "", " ")
+	if err := os.WriteFile(filepath.Join(h.StateDir, historyFile), data, 0o600); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+
+	return nil
+}
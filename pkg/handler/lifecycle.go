@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"golift.io/turbovanityurls/pkg/templates"
+)
+
+// setDeprecated marks a path deprecated if it has a replacement.
+func (p *PathConfig) setDeprecated() {
+	if p.ReplacedBy != "" {
+		p.Deprecated = true
+	}
+}
+
+// ReplacedByURL is used in the template to link the replacement module's page.
+func (p *PathConfig) ReplacedByURL() string {
+	if p.ReplacedBy == "" {
+		return ""
+	}
+
+	return "https://" + p.ReplacedBy
+}
+
+// ShowDeprecated is used in the index template. Returns true if
+// deprecated modules are not hidden, and there is at least one to show.
+func (c *Config) ShowDeprecated() bool {
+	if c.HideDeprecated {
+		return false
+	}
+
+	for _, p := range c.Paths {
+		if p.Deprecated && p.Listed() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Tombstone is passed into the gone template.
+type Tombstone struct {
+	Host       string
+	Path       string
+	IndexTitle string
+	LogoURL    string
+	Message    string
+}
+
+// serveGone responds 410 for paths that were removed on purpose. Browsers get a page;
+// the go tool gets a short text body, because it prints plain text responses in its error.
+func (h *Handler) serveGone(w http.ResponseWriter, r *http.Request, pc *PathReq) {
+	w.Header().Set("Cache-Control", pc.cacheControl)
+
+	if r.URL.Query().Get("go-get") == "1" {
+		message := h.Host + strings.TrimSuffix(pc.Path, "/") + " is gone"
+		if pc.GoneMessage != "" {
+			message += ": " + pc.GoneMessage
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusGone)
+		_, _ = w.Write([]byte(message + "\n"))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+
+	_ = templates.Gone.Execute(w, &Tombstone{
+		Host:       h.Host,
+		Path:       strings.TrimSuffix(pc.Path, "/"),
+		IndexTitle: h.Title,
+		LogoURL:    h.LogoURL,
+		Message:    pc.GoneMessage,
+	})
+}
+
+// moved returns true if the path has moved, and the move has started.
+func (p *PathConfig) moved(now time.Time) bool {
+	return p.MovedTo != "" && !now.Before(p.MovedAt)
+}
+
+// serveMoved redirects browsers to a moved module's new home. The subpath is kept.
+// go-get requests never get here; they keep the old meta tags so existing builds work.
+func (h *Handler) serveMoved(w http.ResponseWriter, r *http.Request, pc *PathReq) {
+	target := pc.MovedTo
+	if pc.Subpath != "" {
+		target = strings.TrimSuffix(target, "/") + "/" + pc.Subpath
+	}
+
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
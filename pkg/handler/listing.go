@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"golift.io/turbovanityurls/pkg/templates"
+)
+
+// Listing is passed into the namespace template. It represents a prefix that
+// has no repo of its own: a bare wildcard path or a parent of nested paths.
+type Listing struct {
+	Host       string
+	Prefix     string
+	IndexTitle string
+	LogoURL    string
+	// PathConfig is only set when the prefix is a wildcard path.
+	*PathConfig
+	// Children are the configured paths nested under Prefix.
+	Children PathConfigs
+}
+
+// serveNamespace renders a directory-style listing page for a path prefix.
+// If the prefix has no children and is not a wildcard, this is a 404.
+func (h *Handler) serveNamespace(w http.ResponseWriter, r *http.Request, pc *PathReq) {
+	list := &Listing{
+		Host:       h.Host,
+		Prefix:     r.URL.Path,
+		IndexTitle: h.Title,
+		LogoURL:    h.LogoURL,
+		PathConfig: pc.PathConfig,
+	}
+
+	if pc.PathConfig != nil {
+		// Wildcard paths are prefixes exactly as configured; /captain- lists /captain-hook.
+		list.Prefix = pc.Path
+		list.Children = h.PathConfigs.Children(pc.Path)
+	} else {
+		list.Prefix = strings.TrimSuffix(list.Prefix, "/") + "/"
+		list.Children = h.PathConfigs.Children(list.Prefix)
+	}
+
+	if list.PathConfig == nil && len(list.Children) == 0 {
+		h.NotFound(w, r)
+		return
+	}
+
+	if list.PathConfig != nil {
+		w.Header().Set("Cache-Control", list.cacheControl)
+	}
+
+	if err := templates.Listing.Execute(w, list); err != nil {
+		http.Error(w, "cannot render the page", http.StatusInternalServerError)
+	}
+}
+
+// Title is used in the template to generate the namespace title.
+func (l *Listing) Title() string {
+	return strings.TrimSuffix(l.Prefix, "/")
+}
+
+// Children returns the configured paths nested under a prefix.
+// The prefix itself, gone paths and inactive paths are not included.
+func (pset PathConfigs) Children(prefix string) PathConfigs {
+	children := PathConfigs{}
+
+	for _, p := range pset {
+		if p.Path != prefix && strings.HasPrefix(p.Path, prefix) && !p.Gone && p.Active() {
+			children = append(children, p)
+		}
+	}
+
+	return children
+}
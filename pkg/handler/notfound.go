@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"golift.io/turbovanityurls/pkg/templates"
+)
+
+// maxSuggestions is the most "did you mean" paths displayed on a 404 page.
+const maxSuggestions = 3
+
+// Missing is passed into the 404 template.
+type Missing struct {
+	Host        string
+	Path        string
+	IndexTitle  string
+	LogoURL     string
+	Suggestions PathConfigs
+}
+
+// NotFound redirects 404 requests if a redirect URL is set.
+// Otherwise it renders a 404 page with suggestions for similar paths.
+func (h *Handler) NotFound(w http.ResponseWriter, r *http.Request) {
+	if h.Redir404 != "" {
+		http.Redirect(w, r, h.Redir404, http.StatusFound)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	_ = templates.NotFound.Execute(w, &Missing{
+		Host:        h.Host,
+		Path:        r.URL.Path,
+		IndexTitle:  h.Title,
+		LogoURL:     h.LogoURL,
+		Suggestions: h.PathConfigs.Suggest(r.URL.Path),
+	})
+}
+
+// Suggest returns the configured paths that most closely resemble the provided path.
+// Comparisons are case-insensitive and based on edit distance. Paths that would
+// match with different letter casing (/Starr/foo for /starr) are suggested first.
+func (pset PathConfigs) Suggest(path string) PathConfigs {
+	type suggestion struct {
+		*PathConfig
+		distance int
+	}
+
+	var (
+		folded      = strings.TrimSuffix(strings.ToLower(path), "/")
+		suggestions = []suggestion{}
+	)
+
+	for _, p := range pset {
+		configured := strings.TrimSuffix(strings.ToLower(p.Path), "/")
+		if configured == "" || p.Gone || !p.Active() {
+			continue // never suggest the root path, or paths that are gone or inactive.
+		}
+
+		distance := 0
+		if !strings.HasPrefix(folded, configured+"/") {
+			if diff := len(folded) - len(configured); diff > len(configured)/3 || -diff > len(configured)/3 {
+				continue // too many typos, no need to count them.
+			}
+
+			distance = levenshtein(folded, configured)
+		}
+
+		// Allow roughly one typo for every three characters.
+		if distance <= len(configured)/3 {
+			suggestions = append(suggestions, suggestion{PathConfig: p, distance: distance})
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	output := PathConfigs{}
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		output = append(output, suggestions[i].PathConfig)
+	}
+
+	return output
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(src, dst string) int {
+	a, b := []rune(src), []rune(dst)
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
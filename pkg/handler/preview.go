@@ -0,0 +1,329 @@
+package handler
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	_ "image/jpeg" // logo files may be jpeg.
+	"image/png"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Preview images are sized for OpenGraph and Twitter large cards.
+const (
+	previewWidth    = 1200
+	previewHeight   = 630
+	previewMargin   = 80
+	previewLogoSize = 160
+	// maxPreviews is how many rendered images are kept. The least recently used image is dropped first.
+	maxPreviews = 1000
+	// Description lines beyond this are dropped.
+	maxPreviewLines = 6
+	// Font sizes and line heights, in pixels.
+	previewTitleSize = 56
+	previewTextSize  = 32
+	previewDPI       = 72
+	svgTitleHeight   = 68
+	svgTextHeight    = 40
+	// SVG text is not measured; these approximate the average glyph widths.
+	svgTitleGlyph = 32
+	svgTextGlyph  = 17
+)
+
+// Default preview colors.
+const (
+	defaultPreviewBG = "#1d2731"
+	defaultPreviewFG = "#f5f5f5"
+)
+
+// ErrInvalidColor is returned when a preview color cannot be parsed.
+var ErrInvalidColor = errors.New("invalid color; use #rrggbb")
+
+// tagRegexp is used to strip HTML from descriptions.
+var tagRegexp = regexp.MustCompile(`<[^>]*>`)
+
+// PreviewConfig controls the generated social preview images.
+// Each vanity path gets an image at /<path>/preview.png and /<path>/preview.svg.
+type PreviewConfig struct {
+	Disable bool `yaml:"disable,omitempty"`
+	// LogoFile is a local png or jpeg file drawn on png previews.
+	// svg previews link to logo_url instead.
+	LogoFile   string `yaml:"logo_file,omitempty"`
+	Background string `yaml:"background,omitempty"`
+	Foreground string `yaml:"foreground,omitempty"`
+}
+
+// previews renders and caches preview images. The cache belongs to a Handler,
+// so a new config (a new Handler) always starts with an empty cache.
+type previews struct {
+	*PreviewConfig
+	logoURL string
+	bg, fg  color.RGBA
+	logo    image.Image
+	title   font.Face
+	text    font.Face
+	mu      sync.Mutex
+	cache   map[string]*list.Element
+	lru     *list.List // of *cachedPreview, most recently used first.
+}
+
+// cachedPreview is a rendered image in the preview cache.
+type cachedPreview struct {
+	key  string
+	data []byte
+}
+
+// newPreviews parses the preview config, fonts, and the logo file.
+func newPreviews(config *PreviewConfig, logoURL string) (*previews, error) {
+	p := &previews{PreviewConfig: config, logoURL: logoURL, cache: make(map[string]*list.Element), lru: list.New()}
+
+	var err error
+
+	if p.bg, err = parseHexColor(config.Background, defaultPreviewBG); err != nil {
+		return nil, fmt.Errorf("preview background: %w", err)
+	}
+
+	if p.fg, err = parseHexColor(config.Foreground, defaultPreviewFG); err != nil {
+		return nil, fmt.Errorf("preview foreground: %w", err)
+	}
+
+	if p.title, err = parseFace(gobold.TTF, previewTitleSize); err != nil {
+		return nil, err
+	}
+
+	if p.text, err = parseFace(goregular.TTF, previewTextSize); err != nil {
+		return nil, err
+	}
+
+	if config.LogoFile == "" {
+		return p, nil
+	}
+
+	file, err := os.Open(config.LogoFile)
+	if err != nil {
+		return nil, fmt.Errorf("opening preview logo: %w", err)
+	}
+	defer file.Close()
+
+	if p.logo, _, err = image.Decode(file); err != nil {
+		return nil, fmt.Errorf("decoding preview logo: %w", err)
+	}
+
+	return p, nil
+}
+
+func parseFace(ttf []byte, size float64) (font.Face, error) {
+	fnt, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font: %w", err)
+	}
+
+	face, err := opentype.NewFace(fnt, &opentype.FaceOptions{Size: size, DPI: previewDPI, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("creating font face: %w", err)
+	}
+
+	return face, nil
+}
+
+// parseHexColor turns #rrggbb into a color. An empty string returns the default.
+func parseHexColor(hex, defaultHex string) (color.RGBA, error) {
+	if hex == "" {
+		hex = defaultHex
+	}
+
+	rgba := color.RGBA{A: 0xff}
+	if _, err := fmt.Sscanf(hex, "#%02x%02x%02x", &rgba.R, &rgba.G, &rgba.B); err != nil || len(hex) != len("#rrggbb") {
+		return rgba, fmt.Errorf("%w: %s", ErrInvalidColor, hex)
+	}
+
+	return rgba, nil
+}
+
+// previewFormat returns png or svg if the request is for a preview image.
+func previewFormat(subpath string) string {
+	switch {
+	case subpath == "preview.png" || strings.HasSuffix(subpath, "/preview.png"):
+		return "png"
+	case subpath == "preview.svg" || strings.HasSuffix(subpath, "/preview.svg"):
+		return "svg"
+	default:
+		return ""
+	}
+}
+
+// PreviewURL is used in the template to link the generated preview image.
+// Returns an empty string if previews are disabled.
+func (p *PathReq) PreviewURL() string {
+	if !p.Previews {
+		return ""
+	}
+
+	return "https://" + p.Host + p.ImportPath() + "/preview.png"
+}
+
+// servePreview renders (or returns a cached) preview image for a vanity path.
+func (p *previews) servePreview(w http.ResponseWriter, pc *PathReq, format string) {
+	title := pc.Host + pc.configuredPath()
+	data := p.get(title+"."+format, func() []byte {
+		desc := html.UnescapeString(tagRegexp.ReplaceAllString(pc.Description, ""))
+		if format == "svg" {
+			return p.renderSVG(title, desc)
+		}
+
+		return p.renderPNG(title, desc)
+	})
+
+	w.Header().Set("Cache-Control", pc.cacheControl)
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+	} else {
+		w.Header().Set("Content-Type", "image/png")
+	}
+
+	_, _ = w.Write(data)
+}
+
+// get returns a cached image, or renders and caches it.
+// Font faces are not safe for concurrent use, so rendering happens under the lock too.
+func (p *previews) get(key string, render func() []byte) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if elem, ok := p.cache[key]; ok {
+		p.lru.MoveToFront(elem)
+		return elem.Value.(*cachedPreview).data //nolint:forcetypeassert
+	}
+
+	data := render()
+	p.cache[key] = p.lru.PushFront(&cachedPreview{key: key, data: data})
+
+	if p.lru.Len() > maxPreviews {
+		delete(p.cache, p.lru.Remove(p.lru.Back()).(*cachedPreview).key) //nolint:forcetypeassert
+	}
+
+	return data
+}
+
+func (p *previews) renderPNG(title, desc string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, previewWidth, previewHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(p.bg), image.Point{}, draw.Src)
+
+	textWidth := previewWidth - 2*previewMargin
+
+	if p.logo != nil {
+		textWidth -= previewLogoSize + previewMargin
+		logoRect := image.Rect(previewWidth-previewMargin-previewLogoSize, previewMargin,
+			previewWidth-previewMargin, previewMargin+previewLogoSize)
+		draw.ApproxBiLinear.Scale(img, logoRect, p.logo, p.logo.Bounds(), draw.Over, nil)
+	}
+
+	drawer := &font.Drawer{Dst: img, Src: image.NewUniform(p.fg), Face: p.title}
+	y := previewMargin + p.title.Metrics().Ascent.Ceil()
+
+	for _, line := range wrapText(title, textWidth, func(s string) int { return drawer.MeasureString(s).Ceil() }) {
+		drawer.Dot = fixed.P(previewMargin, y)
+		drawer.DrawString(line)
+		y += p.title.Metrics().Height.Ceil()
+	}
+
+	drawer.Face = p.text
+	y += p.text.Metrics().Height.Ceil()
+
+	for _, line := range wrapText(desc, textWidth, func(s string) int { return drawer.MeasureString(s).Ceil() }) {
+		drawer.Dot = fixed.P(previewMargin, y)
+		drawer.DrawString(line)
+		y += p.text.Metrics().Height.Ceil()
+	}
+
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+
+	return buf.Bytes()
+}
+
+func (p *previews) renderSVG(title, desc string) []byte {
+	var buf bytes.Buffer
+
+	hexColor := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+	titleWidth := func(s string) int { return len([]rune(s)) * svgTitleGlyph }
+	textWidth := func(s string) int { return len([]rune(s)) * svgTextGlyph }
+	width := previewWidth - 2*previewMargin
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d">`,
+		previewWidth, previewHeight)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(p.bg))
+
+	if p.logoURL != "" {
+		width -= previewLogoSize + previewMargin
+		fmt.Fprintf(&buf, `<image href="%s" x="%d" y="%d" width="%d" height="%d"/>`, html.EscapeString(p.logoURL),
+			previewWidth-previewMargin-previewLogoSize, previewMargin, previewLogoSize, previewLogoSize)
+	}
+
+	fmt.Fprintf(&buf, `<g fill="%s" font-family="Go, Helvetica, Arial, sans-serif">`, hexColor(p.fg))
+
+	y := previewMargin + previewTitleSize
+	for _, line := range wrapText(title, width, titleWidth) {
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="%d" font-weight="bold">%s</text>`,
+			previewMargin, y, previewTitleSize, html.EscapeString(line))
+		y += svgTitleHeight
+	}
+
+	y += svgTextHeight
+
+	for _, line := range wrapText(desc, width, textWidth) {
+		fmt.Fprintf(&buf, `<text x="%d" y="%d" font-size="%d">%s</text>`,
+			previewMargin, y, previewTextSize, html.EscapeString(line))
+		y += svgTextHeight
+	}
+
+	buf.WriteString(`</g></svg>`)
+
+	return buf.Bytes()
+}
+
+// wrapText splits text into lines no wider than width, as reported by measure.
+// Words wider than a line are placed on their own line. Output is truncated.
+func wrapText(text string, width int, measure func(string) int) []string {
+	lines := []string{}
+	line := ""
+
+	for _, word := range strings.Fields(text) {
+		switch {
+		case line == "":
+			line = word
+		case measure(line+" "+word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxPreviewLines {
+		lines = lines[:maxPreviewLines]
+		lines[maxPreviewLines-1] += " …"
+	}
+
+	return lines
+}
@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Redirect rule match types.
+const (
+	MatchPrefix = "prefix"
+	MatchGlob   = "glob"
+	MatchRegex  = "regex"
+)
+
+// ErrInvalidRedirect is returned when a redirect rule cannot be used.
+var ErrInvalidRedirect = errors.New("invalid redirect rule")
+
+// RedirectRule redirects requests that match a pattern to a target.
+// Global rules match the request path, like /old/thing.
+// Path rules match the subpath after the configured path, like thing/v2.
+//
+// Every match type is turned into a regular expression, so targets may use
+// captures from all of them: $1, ${1}, or ${name} for named regex groups.
+// A prefix rule captures everything after the prefix in $1.
+// A glob rule captures each * (one path segment) and ** (any number of segments).
+type RedirectRule struct {
+	Match  string `yaml:"match"`
+	Type   string `yaml:"type,omitempty"`   // prefix (default), glob or regex.
+	Target string `yaml:"target"`           // URL or path, with $1 style captures.
+	Status int    `yaml:"status,omitempty"` // 301, 302 (default), 307 or 308.
+	// KeepQuery appends the request's query string to the target.
+	KeepQuery bool `yaml:"keep_query,omitempty"`
+	// PublishAt and ExpireAt limit when the rule is used. Zero times are not limits.
+	PublishAt time.Time `yaml:"publish_at,omitempty"`
+	ExpireAt  time.Time `yaml:"expire_at,omitempty"`
+	re        *regexp.Regexp
+}
+
+// setRedirects validates and compiles a list of redirect rules.
+func setRedirects(rules []*RedirectRule) error {
+	for _, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compile checks a rule and builds its regular expression.
+func (r *RedirectRule) compile() error {
+	if r.Match == "" || r.Target == "" {
+		return fmt.Errorf("%w: match and target are required: %q", ErrInvalidRedirect, r.Match)
+	}
+
+	switch r.Status {
+	case 0:
+		r.Status = http.StatusFound
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return fmt.Errorf("%w: %q: status must be 301, 302, 307 or 308, not %d", ErrInvalidRedirect, r.Match, r.Status)
+	}
+
+	var expr string
+
+	switch r.Type {
+	case "", MatchPrefix:
+		r.Type = MatchPrefix
+		expr = "^" + regexp.QuoteMeta(r.Match) + "(.*)$"
+	case MatchGlob:
+		expr = globRegexp(r.Match)
+	case MatchRegex:
+		expr = r.Match
+	default:
+		return fmt.Errorf("%w: %q: unknown type: %s", ErrInvalidRedirect, r.Match, r.Type)
+	}
+
+	var err error
+	if r.re, err = regexp.Compile(expr); err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrInvalidRedirect, r.Match, err)
+	}
+
+	return nil
+}
+
+// globRegexp converts a glob into an anchored regular expression.
+// Each * and ? matches within one path segment; ** matches across segments.
+func globRegexp(glob string) string {
+	var expr strings.Builder
+
+	expr.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString("(.*)")
+			i++
+		case glob[i] == '*':
+			expr.WriteString("([^/]*)")
+		case glob[i] == '?':
+			expr.WriteString("([^/])")
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return expr.String()
+}
+
+// Expand returns the target for a subject, and false if the rule doesn't match.
+func (r *RedirectRule) Expand(subject, rawQuery string) (string, bool) {
+	match := r.re.FindStringSubmatchIndex(subject)
+	if match == nil {
+		return "", false
+	}
+
+	target := string(r.re.ExpandString(nil, r.Target, subject, match))
+
+	if r.KeepQuery && rawQuery != "" {
+		if strings.Contains(target, "?") {
+			target += "&" + rawQuery
+		} else {
+			target += "?" + rawQuery
+		}
+	}
+
+	return target, true
+}
+
+// serveRedirect checks the path rules, then the global rules, and redirects
+// on the first match. Returns true if a redirect was written. The go tool
+// always gets its meta tags, so go-get requests for repos are not redirected.
+func (h *Handler) serveRedirect(w http.ResponseWriter, r *http.Request, pc *PathReq) bool {
+	if pc.PathConfig != nil && pc.Repo != "" && r.URL.Query().Get("go-get") == "1" {
+		return false
+	}
+
+	now := h.clock.Now()
+
+	if pc.PathConfig != nil {
+		for _, rule := range pc.Redirects {
+			if !scheduled(now, rule.PublishAt, rule.ExpireAt) {
+				continue
+			}
+
+			if target, ok := rule.Expand(pc.Subpath, r.URL.RawQuery); ok {
+				http.Redirect(w, r, target, rule.Status)
+				return true
+			}
+		}
+	}
+
+	for _, rule := range h.Redirects {
+		if !scheduled(now, rule.PublishAt, rule.ExpireAt) {
+			continue
+		}
+
+		if target, ok := rule.Expand(r.URL.Path, r.URL.RawQuery); ok {
+			http.Redirect(w, r, target, rule.Status)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match modes for redir_paths.
+const (
+	RedirContains = "contains"
+	RedirPrefix   = "prefix"
+	RedirSegment  = "segment"
+	RedirSuffix   = "suffix"
+	RedirGlob     = "glob"
+)
+
+// ErrRedirMatch is returned when redir_match is not a known mode.
+var ErrRedirMatch = errors.New("unknown redir_match mode")
+
+// setRedirMatch checks the redir_paths match mode, and compiles globs.
+func (p *PathConfig) setRedirMatch(global string) error {
+	if p.RedirMatch == "" {
+		p.RedirMatch = global
+	}
+
+	switch p.RedirMatch {
+	case "":
+		p.RedirMatch = RedirContains
+	case RedirContains, RedirPrefix, RedirSegment, RedirSuffix:
+	case RedirGlob:
+		p.redirGlobs = make([]*regexp.Regexp, len(p.RedirPaths))
+		for i, glob := range p.RedirPaths {
+			p.redirGlobs[i] = regexp.MustCompile(globRegexp(glob)) // globRegexp quotes everything.
+		}
+	default:
+		return fmt.Errorf("%w: %s: %s", ErrRedirMatch, p.Path, p.RedirMatch)
+	}
+
+	return nil
+}
+
+// redirMatches returns true if the subpath matches redir_paths[idx] using the path's match mode.
+func (p *PathConfig) redirMatches(idx int, subpath string) bool {
+	pattern := p.RedirPaths[idx]
+
+	switch p.RedirMatch {
+	case RedirPrefix:
+		return strings.HasPrefix(subpath, pattern)
+	case RedirSuffix:
+		return strings.HasSuffix(subpath, pattern)
+	case RedirGlob:
+		return p.redirGlobs[idx].MatchString(subpath)
+	case RedirSegment:
+		for _, segment := range strings.Split(subpath, "/") {
+			if segment == pattern {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return strings.Contains(subpath, pattern)
+	}
+}
+
+// redirWarnings returns problems with a path's redir_paths that don't stop the app from working.
+// Patterns overlap when one of them matches the other, so the second one never matters.
+// Known repos that match a pattern are shadowed; browsers get redirected instead of the vanity page.
+func (p *PathConfig) redirWarnings() []string {
+	if p.Redir == "" {
+		return nil
+	}
+
+	warnings := []string{}
+
+	for i, pattern := range p.RedirPaths {
+		for j, other := range p.RedirPaths {
+			if i != j && p.redirMatches(i, other) && (i < j || !p.redirMatches(j, pattern)) {
+				warnings = append(warnings, fmt.Sprintf("%s: redir_paths %q overlaps %q (redir_match: %s)",
+					p.Path, pattern, other, p.RedirMatch))
+			}
+		}
+
+		for _, repo := range p.KnownRepos {
+			if p.redirMatches(i, repo) {
+				warnings = append(warnings, fmt.Sprintf("%s: redir_paths %q shadows known repo %q (redir_match: %s)",
+					p.Path, pattern, repo, p.RedirMatch))
+			}
+		}
+	}
+
+	return warnings
+}
+
+// Warnings returns configuration problems that don't stop the app from starting.
+// These are printed by the -validate flag.
+func (h *Handler) Warnings() []string {
+	warnings := []string{}
+
+	for _, p := range h.PathConfigs {
+		warnings = append(warnings, p.redirWarnings()...)
+	}
+
+	return warnings
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"time"
+)
+
+// clock is shared by the handler, config and paths, so SetClock changes all of them.
+type clock struct {
+	now func() time.Time
+}
+
+// Notice is a banner displayed between start and end. Site-wide notices are
+// displayed on the index and every package page. Path notices are displayed
+// on that path's package page.
+type Notice struct {
+	Message string    `yaml:"message"` // HTML is OK.
+	Start   time.Time `yaml:"start,omitempty"`
+	End     time.Time `yaml:"end,omitempty"`
+}
+
+// SetClock replaces the clock used for scheduled settings,
+// like publish_at, expire_at, moved_at and notices. Used in tests.
+func (h *Handler) SetClock(now func() time.Time) {
+	h.clock.now = now
+}
+
+// Now returns the current time from the clock. A nil clock uses the system time.
+func (c *clock) Now() time.Time {
+	if c == nil || c.now == nil {
+		return time.Now()
+	}
+
+	return c.now()
+}
+
+// scheduled returns true if now is inside a schedule. Zero times are not limits.
+func scheduled(now, start, end time.Time) bool {
+	return !now.Before(start) && (end.IsZero() || now.Before(end))
+}
+
+// Active returns true if the path is published and not expired.
+// Inactive paths are treated like they are not configured.
+func (p *PathConfig) Active() bool {
+	return scheduled(p.clock.Now(), p.PublishAt, p.ExpireAt)
+}
+
+// findActive is Find without inactive paths, so they are treated like they are not
+// configured: a request for an expired /org/conf is served by /org, if that is configured.
+func (pset PathConfigs) findActive(path string) PathReq {
+	pc := pset.Find(path)
+	if pc.PathConfig == nil || pc.Active() {
+		return pc
+	}
+
+	active := make(PathConfigs, 0, len(pset))
+
+	for _, p := range pset {
+		if p.Active() {
+			active = append(active, p)
+		}
+	}
+
+	return active.Find(path)
+}
+
+// ActiveNotices is used in the index template to display site-wide notices.
+func (c *Config) ActiveNotices() []*Notice {
+	return activeNotices(c.clock.Now(), c.Notices)
+}
+
+// ActiveNotices is used in the vanity template to display site-wide and path notices.
+func (p *PathReq) ActiveNotices() []*Notice {
+	return activeNotices(p.clock.Now(), p.SiteNotices, p.Notices)
+}
+
+func activeNotices(now time.Time, lists ...[]*Notice) []*Notice {
+	notices := []*Notice{}
+
+	for _, list := range lists {
+		for _, notice := range list {
+			if scheduled(now, notice.Start, notice.End) {
+				notices = append(notices, notice)
+			}
+		}
+	}
+
+	return notices
+}
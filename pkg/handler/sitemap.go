@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RobotsConfig controls the generated /robots.txt file.
+type RobotsConfig struct {
+	// Disallow is a list of extra paths crawlers should not visit.
+	Disallow []string `yaml:"disallow,omitempty"`
+	// HideUnlisted disallows paths that have `unlisted` set.
+	HideUnlisted bool `yaml:"hide_unlisted,omitempty"`
+	// HideRedirects disallows paths that only redirect (have no repo).
+	HideRedirects bool `yaml:"hide_redirects,omitempty"`
+}
+
+// sitemapURLSet is the root of a sitemap.xml document.
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Listed returns true if the path is a vanity page that belongs in the index and sitemap.
+func (p *PathConfig) Listed() bool {
+	return p.Repo != "" && !p.Wildcard && p.Name == "" && !p.Unlisted && !p.Gone && p.Active()
+}
+
+// Sitemap renders /sitemap.xml with the index page and every listed vanity page.
+func (h *Handler) Sitemap(w http.ResponseWriter, _ *http.Request) {
+	lastMod := h.Loaded.Format(time.RFC3339)
+	urlset := &sitemapURLSet{
+		XMLNS: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		URLs:  []sitemapURL{{Loc: "https://" + h.Host + "/", LastMod: lastMod}},
+	}
+
+	for _, p := range h.PathConfigs {
+		if p.Listed() && p.Path != "/" {
+			urlset.URLs = append(urlset.URLs, sitemapURL{
+				Loc:     "https://" + h.Host + strings.TrimSuffix(p.Path, "/"),
+				LastMod: lastMod,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(urlset); err != nil {
+		http.Error(w, "cannot render the sitemap", http.StatusInternalServerError)
+	}
+}
+
+// RobotsTxt renders /robots.txt. It points crawlers to the sitemap.
+func (h *Handler) RobotsTxt(w http.ResponseWriter, _ *http.Request) {
+	var body strings.Builder
+
+	body.WriteString("User-agent: *\n")
+
+	disallow := append([]string{}, h.Robots.Disallow...)
+
+	for _, p := range h.PathConfigs {
+		if (h.Robots.HideUnlisted && p.Unlisted) || (h.Robots.HideRedirects && p.Repo == "" && p.Redir != "") {
+			disallow = append(disallow, p.Path)
+		}
+	}
+
+	if len(disallow) == 0 {
+		body.WriteString("Disallow:\n")
+	}
+
+	for _, path := range disallow {
+		body.WriteString("Disallow: " + path + "\n")
+	}
+
+	body.WriteString("\nSitemap: https://" + h.Host + "/sitemap.xml\n")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(body.String()))
+}
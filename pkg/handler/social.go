@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+)
+
+// defaultCard is the twitter card type used when one is not configured.
+const defaultCard = "summary"
+
+// SocialConfig controls the OpenGraph, Twitter card, and JSON-LD metadata on pages.
+// The global config applies to the index page, and provides defaults for paths.
+type SocialConfig struct {
+	Disable     bool   `yaml:"disable,omitempty"`
+	Title       string `yaml:"title,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	ImageURL    string `yaml:"image_url,omitempty"`
+	Card        string `yaml:"card,omitempty"` // summary or summary_large_image
+	TwitterSite string `yaml:"twitter_site,omitempty"`
+}
+
+// setSocial fills in the path's social settings from the global settings.
+// Title, description and image are not inherited; they belong to the index page.
+func (p *PathConfig) setSocial(global *SocialConfig) {
+	if p.Social == nil {
+		p.Social = &SocialConfig{}
+	}
+
+	p.Social.Disable = p.Social.Disable || global.Disable
+
+	if p.Social.Card == "" {
+		p.Social.Card = global.Card
+	}
+
+	if p.Social.TwitterSite == "" {
+		p.Social.TwitterSite = global.TwitterSite
+	}
+}
+
+// MetaTitle is used in the template as the og:title for a vanity page.
+func (p *PathReq) MetaTitle() string {
+	if p.Social.Title != "" {
+		return p.Social.Title
+	}
+
+	return p.Host + p.ImportPath()
+}
+
+// MetaDescription is used in the template as the og:description for a vanity page.
+func (p *PathReq) MetaDescription() string {
+	if p.Social.Description != "" {
+		return p.Social.Description
+	}
+
+	if p.Description != "" {
+		return p.Description
+	}
+
+	return "Go package " + p.Host + p.ImportPath()
+}
+
+// MetaImage is used in the template as the og:image for a vanity page.
+// A generated preview image is preferred over the site logo.
+func (p *PathReq) MetaImage() string {
+	switch {
+	case p.Social.ImageURL != "":
+		return p.Social.ImageURL
+	case p.ImageURL != "":
+		return p.ImageURL
+	case p.Previews:
+		return p.PreviewURL()
+	default:
+		return p.LogoURL
+	}
+}
+
+// softwareSourceCode is the schema.org JSON-LD structure for a vanity page.
+type softwareSourceCode struct {
+	Context        string `json:"@context"`
+	Type           string `json:"@type"`
+	Name           string `json:"name"`
+	Description    string `json:"description,omitempty"`
+	URL            string `json:"url"`
+	CodeRepository string `json:"codeRepository,omitempty"`
+	Language       string `json:"programmingLanguage"`
+	License        string `json:"license,omitempty"`
+}
+
+// JSONLD is used in the template to provide structured data for a vanity page.
+// The output is safe to place inside a script tag; < > & are escaped.
+func (p *PathReq) JSONLD() string {
+	data, _ := json.Marshal(&softwareSourceCode{
+		Context:        "https://schema.org",
+		Type:           "SoftwareSourceCode",
+		Name:           p.Host + p.ImportPath(),
+		Description:    p.MetaDescription(),
+		URL:            "https://" + p.Host + p.ImportPath(),
+		CodeRepository: p.RepoPath(),
+		Language:       "Go",
+		License:        p.License,
+	})
+
+	return string(data)
+}
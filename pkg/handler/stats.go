@@ -0,0 +1,286 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golift.io/turbovanityurls/pkg/templates"
+)
+
+// statsFile is the name of the file in state_dir that stores request counters.
+const statsFile = "stats.json"
+
+// Stats defaults and request kinds.
+const (
+	defaultStatsDays  = 90
+	statsSaveInterval = time.Minute
+	statsDateFormat   = "2006-01-02"
+	kindGoGet         = "go-get"
+	kindBrowser       = "browser"
+)
+
+// ErrStatsNoAuth is returned when the stats page or api is enabled without a password.
+var ErrStatsNoAuth = errors.New("stats require a username and password")
+
+// majorRegexp matches a major version subpath, like v2.
+var majorRegexp = regexp.MustCompile(`^v([2-9]|[1-9][0-9])$`)
+
+// StatsConfig controls request statistics. Stats are kept in memory,
+// and saved to state_dir if it is set.
+type StatsConfig struct {
+	// Path is where the stats page is served, like /stats. Disabled if empty.
+	Path     string `yaml:"path,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Days is how many days of counters are kept.
+	Days int `yaml:"days,omitempty"`
+}
+
+// Stats are daily request counters for each import path.
+type Stats struct {
+	// Days maps a date to import paths, to counters. Counters are named kind/version.
+	// Example: Days["2024-06-01"]["/unifi"]["go-get/v2"] = 4
+	Days  map[string]map[string]map[string]uint64 `json:"days"`
+	file  string
+	keep  int
+	saved time.Time
+	dirty bool
+	mu    sync.Mutex
+}
+
+// StatsRow is a summary of counters for one import path. Used in the stats page and api.
+type StatsRow struct {
+	ImportPath string            `json:"importPath"`
+	GoGet      uint64            `json:"goGet"`
+	Browser    uint64            `json:"browser"`
+	Counters   map[string]uint64 `json:"counters"`
+}
+
+// statsPage is passed into the stats template.
+type statsPage struct {
+	Title string
+	Host  string
+	Days  int
+	Rows  []*StatsRow
+}
+
+// loadStats reads the stats state file, if state_dir is set.
+func (h *Handler) loadStats() error {
+	if h.Stats.Path != "" && (h.Stats.Username == "" || h.Stats.Password == "") {
+		return ErrStatsNoAuth
+	}
+
+	if h.Stats.Days < 1 {
+		h.Stats.Days = defaultStatsDays
+	}
+
+	h.stats = &Stats{Days: make(map[string]map[string]map[string]uint64), keep: h.Stats.Days, saved: time.Now()}
+
+	if h.StateDir == "" {
+		return nil
+	}
+
+	h.stats.file = filepath.Join(h.StateDir, statsFile)
+
+	data, err := os.ReadFile(h.stats.file)
+	if errors.Is(err, os.ErrNotExist) || len(data) == 0 {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, h.stats); err != nil {
+		return fmt.Errorf("parsing state file: %w", err)
+	}
+
+	return nil
+}
+
+// countRequest records a vanity page request. The major version is taken from
+// the subpath, so golift.io/unifi/v2/pkg counts as v2 and golift.io/unifi/pkg as v1.
+func (h *Handler) countRequest(r *http.Request, pc *PathReq) {
+	kind := kindBrowser
+	if r.URL.Query().Get("go-get") == "1" {
+		kind = kindGoGet
+	}
+
+	version := "v1"
+	subpath := pc.Subpath
+
+	if pc.Wildcard {
+		// The wildcard subpath starts with the repo name.
+		_, subpath, _ = pc.WildcardName()
+	}
+
+	if major := strings.Split(subpath, "/")[0]; majorRegexp.MatchString(major) {
+		version = major
+	}
+
+	h.stats.add(time.Now(), pc.configuredPath(), kind+"/"+version)
+}
+
+func (s *Stats) add(now time.Time, importPath, counter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	date := now.UTC().Format(statsDateFormat)
+	if s.Days[date] == nil {
+		s.Days[date] = make(map[string]map[string]uint64)
+	}
+
+	if s.Days[date][importPath] == nil {
+		s.Days[date][importPath] = make(map[string]uint64)
+	}
+
+	s.Days[date][importPath][counter]++
+	s.dirty = true
+
+	if s.file != "" && now.Sub(s.saved) >= statsSaveInterval {
+		if err := s.save(now); err != nil {
+			log.Printf("[ERROR] Saving stats: %v", err)
+		}
+	}
+}
+
+// Save writes the stats to the state file. Call this before exiting.
+func (s *Stats) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.save(time.Now())
+}
+
+// save prunes old days and writes the state file. Must be called with the lock held.
+func (s *Stats) save(now time.Time) error {
+	s.saved = now
+
+	if !s.dirty || s.file == "" {
+		return nil
+	}
+
+	oldest := now.UTC().AddDate(0, 0, -s.keep).Format(statsDateFormat)
+	for date := range s.Days {
+		if date < oldest {
+			delete(s.Days, date)
+		}
+	}
+
+	data, _ := json.Marshal(s)
+	if err := os.WriteFile(s.file, data, 0o600); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+
+	s.dirty = false
+
+	return nil
+}
+
+// Total returns the total count of a kind of request for an import path.
+func (s *Stats) Total(importPath, kind string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var total uint64
+
+	for _, paths := range s.Days {
+		for counter, count := range paths[importPath] {
+			if strings.HasPrefix(counter, kind+"/") {
+				total += count
+			}
+		}
+	}
+
+	return total
+}
+
+// Report returns a summary of all counters for each import path.
+func (s *Stats) Report() []*StatsRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rows := make(map[string]*StatsRow)
+
+	for _, paths := range s.Days {
+		for importPath, counters := range paths {
+			row := rows[importPath]
+			if row == nil {
+				row = &StatsRow{ImportPath: importPath, Counters: make(map[string]uint64)}
+				rows[importPath] = row
+			}
+
+			for counter, count := range counters {
+				row.Counters[counter] += count
+
+				if strings.HasPrefix(counter, kindGoGet+"/") {
+					row.GoGet += count
+				} else {
+					row.Browser += count
+				}
+			}
+		}
+	}
+
+	report := make([]*StatsRow, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, row)
+	}
+
+	sort.Slice(report, func(i, j int) bool { return report[i].ImportPath < report[j].ImportPath })
+
+	return report
+}
+
+// authorized checks the request's basic auth against the stats credentials.
+func (h *Handler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	user, pass, ok := r.BasicAuth()
+	if ok && subtle.ConstantTimeCompare([]byte(user), []byte(h.Stats.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(h.Stats.Password)) == 1 {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="stats"`)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+	return false
+}
+
+// StatsPage renders the stats page.
+func (h *Handler) StatsPage(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(w, r) {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	err := templates.Stats.Execute(w, &statsPage{Title: h.Title, Host: h.Host, Days: h.Stats.Days, Rows: h.stats.Report()})
+	if err != nil {
+		http.Error(w, "cannot render the page", http.StatusInternalServerError)
+	}
+}
+
+// KeepStats counts requests in the stats of a running handler, so replacing the running
+// handler does not lose counters. The state_dir and days settings from h are kept.
+func (h *Handler) KeepStats(running *Handler) {
+	running.stats.mu.Lock()
+	defer running.stats.mu.Unlock()
+
+	running.stats.file = h.stats.file
+	running.stats.keep = h.stats.keep
+	h.stats = running.stats
+}
+
+// SaveStats writes request counters to the state file, if one is configured.
+func (h *Handler) SaveStats() error {
+	return h.stats.Save()
+}
@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrRepoTemplate is returned when a wildcard setting cannot be used.
+var ErrRepoTemplate = errors.New("invalid repo_template")
+
+// setWildcard checks repo_template and wildcard_depth. If repo is empty,
+// it's set to the text before the first placeholder in repo_template.
+// Repo is still used to find the VCS type and in namespace listings.
+func (p *PathConfig) setWildcard() error {
+	if !p.Wildcard && (p.RepoTemplate != "" || p.WildcardDepth != 0) {
+		return fmt.Errorf("%w: %s: repo_template and wildcard_depth require wildcard: true", ErrRepoTemplate, p.Path)
+	}
+
+	if p.WildcardDepth < 0 {
+		return fmt.Errorf("%w: %s: wildcard_depth must be positive: %d", ErrRepoTemplate, p.Path, p.WildcardDepth)
+	}
+
+	if p.Wildcard && p.WildcardDepth == 0 {
+		p.WildcardDepth = 1
+	}
+
+	if p.Repo == "" && p.RepoTemplate != "" {
+		p.Repo, _, _ = strings.Cut(p.RepoTemplate, "{")
+	}
+
+	return nil
+}
+
+// WildcardName splits a wildcard subpath into the repo name and the rest of the subpath.
+// The name is the first wildcard_depth segments. Returns false if the subpath has fewer segments.
+func (p *PathReq) WildcardName() (string, string, bool) {
+	segments := strings.SplitN(p.Subpath, "/", p.WildcardDepth+1)
+	if len(segments) < p.WildcardDepth || segments[p.WildcardDepth-1] == "" {
+		return strings.Join(segments, "/"), "", false
+	}
+
+	name := strings.Join(segments[:p.WildcardDepth], "/")
+
+	return name, strings.TrimPrefix(p.Subpath[len(name):], "/"), true
+}
+
+// hasWildcardName returns true if the subpath has a complete wildcard repo name.
+func (p *PathReq) hasWildcardName() bool {
+	_, _, ok := p.WildcardName()
+	return ok
+}
+
+// wildcardPrefix returns true if a prefix (from splitSubpath) is valid for this path.
+// Only wildcard paths have a repo name before a -/ segment, and it must be the whole name.
+func (p *PathReq) wildcardPrefix(prefix string) bool {
+	if !p.Wildcard {
+		return prefix == ""
+	}
+
+	name, rest, ok := p.WildcardName()
+
+	return ok && rest != "" && prefix == name+"/"
+}
+
+// configuredPath returns the configured path for a request, without a trailing slash.
+// Wildcard repo names come from the request, so only names in known_repos are added.
+// Previews and request stats use this, so random names cannot grow them without limit.
+func (p *PathReq) configuredPath() string {
+	if !p.Wildcard {
+		return strings.TrimSuffix(p.Path, "/")
+	}
+
+	if name, _, _ := p.WildcardName(); slices.Contains(p.KnownRepos, name) {
+		return strings.TrimSuffix(p.Path+name, "/")
+	}
+
+	return strings.TrimSuffix(p.Path, "/")
+}
+
+// templateRepo fills in the repo_template placeholders for a wildcard repo name.
+func (p *PathReq) templateRepo(name string) string {
+	pairs := []string{
+		"{name}", name,
+		"{matched_prefix}", strings.TrimPrefix(p.Path, "/"),
+	}
+
+	for i, segment := range strings.Split(name, "/") {
+		pairs = append(pairs, "{segment"+strconv.Itoa(i+1)+"}", segment)
+	}
+
+	return strings.NewReplacer(pairs...).Replace(p.RepoTemplate)
+}
+
+// nameFilter decides which wildcard repo names are served.
+// The allow file is read again whenever its size or modification time changes.
+type nameFilter struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+	file  string
+	mu    sync.Mutex
+	stamp string
+	// fileAllow are the patterns from the allow file.
+	fileAllow []*regexp.Regexp
+}
+
+// setNameFilter compiles the allow and deny lists, and reads the allow file.
+func (p *PathConfig) setNameFilter() error {
+	if len(p.Allow) == 0 && len(p.Deny) == 0 && p.AllowFile == "" {
+		return nil
+	}
+
+	if !p.Wildcard {
+		return fmt.Errorf("%w: %s: allow, deny and allow_file require wildcard: true", ErrRepoTemplate, p.Path)
+	}
+
+	p.names = &nameFilter{allow: namePatterns(p.Allow), deny: namePatterns(p.Deny), file: p.AllowFile}
+
+	if p.AllowFile == "" {
+		return nil
+	}
+
+	if err := p.names.reload(); err != nil {
+		return fmt.Errorf("%s: %w", p.Path, err)
+	}
+
+	return nil
+}
+
+// namePatterns compiles names and globs. Every name is a glob; names without wildcards match exactly.
+func namePatterns(list []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, 0, len(list))
+
+	for _, name := range list {
+		if name = strings.TrimSpace(name); name != "" && !strings.HasPrefix(name, "#") {
+			patterns = append(patterns, regexp.MustCompile(globRegexp(name))) // globRegexp quotes everything.
+		}
+	}
+
+	return patterns
+}
+
+// reload reads the allow file if it changed. Must be called with the lock held, or before serving.
+func (n *nameFilter) reload() error {
+	info, err := os.Stat(n.file)
+	if err != nil {
+		return fmt.Errorf("reading allow file: %w", err)
+	}
+
+	stamp := fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano())
+	if stamp == n.stamp {
+		return nil
+	}
+
+	data, err := os.ReadFile(n.file)
+	if err != nil {
+		return fmt.Errorf("reading allow file: %w", err)
+	}
+
+	n.fileAllow = namePatterns(strings.Split(string(data), "\n"))
+	n.stamp = stamp
+
+	return nil
+}
+
+// allowed returns true if the wildcard repo name is not denied, and is allowed if there is an allowlist.
+func (n *nameFilter) allowed(name string) bool {
+	if n == nil {
+		return true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.file != "" {
+		if err := n.reload(); err != nil {
+			// Keep using the last good list.
+			log.Printf("[ERROR] %v", err)
+		}
+	}
+
+	for _, deny := range n.deny {
+		if deny.MatchString(name) {
+			return false
+		}
+	}
+
+	if len(n.allow) == 0 && n.file == "" {
+		return true
+	}
+
+	for _, list := range [][]*regexp.Regexp{n.allow, n.fileAllow} {
+		for _, allow := range list {
+			if allow.MatchString(name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// allowedName returns true if the request's wildcard repo name may be served.
+func (p *PathReq) allowedName() bool {
+	name, _, _ := p.WildcardName()
+	return p.names.allowed(name)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix for environment variables that override config parameters.
+// The rest of the name is the parameter in upper case, so bd_path is TVU_BD_PATH.
+const EnvPrefix = "TVU_"
+
+// ErrEnvVar is returned when an environment variable is missing or cannot be used.
+var ErrEnvVar = errors.New("environment variable")
+
+// envVarRegexp matches $${ (an escaped ${), ${VAR} and ${VAR:-default}. Names are upper case,
+// so redirect targets may still use regex captures like ${1} and ${name}.
+var envVarRegexp = regexp.MustCompile(`\$\$\{|\$\{([A-Z_][A-Z0-9_]*)(:-([^}]*))?\}`) //nolint:gochecknoglobals
+
+// interpolate replaces ${VAR} and ${VAR:-default} with values from the environment.
+// ${VAR} must be set, but may be empty. The default is used if VAR is unset or empty.
+func interpolate(value string) (string, error) {
+	var missing []string
+
+	value = envVarRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$${" {
+			return "${"
+		}
+
+		groups := envVarRegexp.FindStringSubmatch(match)
+		env, ok := os.LookupEnv(groups[1])
+
+		switch {
+		case groups[2] != "" && env == "":
+			return groups[3]
+		case !ok:
+			missing = append(missing, groups[1])
+		}
+
+		return env
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w is not set: %s", ErrEnvVar, strings.Join(missing, ", "))
+	}
+
+	return value, nil
+}
+
+// interpolateNode replaces environment variables in every value of a YAML document.
+// Keys and comments are not changed. Key is the location of the node, for errors.
+func interpolateNode(node *yaml.Node, key string) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := interpolate(node.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		if value != node.Value && node.Style == 0 {
+			// Resolve the type again, so ${CACHE_AGE:-3600} is still a number.
+			node.Tag = ""
+		}
+
+		node.Value = value
+
+		return nil
+	}
+
+	for i, child := range node.Content {
+		childKey := key
+
+		switch node.Kind { //nolint:exhaustive
+		case yaml.MappingNode:
+			if i%2 == 0 {
+				continue // This is a key.
+			}
+
+			childKey = strings.TrimPrefix(key+"."+node.Content[i-1].Value, ".")
+		case yaml.SequenceNode:
+			childKey = fmt.Sprintf("%s[%d]", key, i)
+		}
+
+		if err := interpolateNode(child, childKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setEnv overrides top-level parameters with TVU_ environment variables.
+// Only parameters with a single value (strings, numbers and booleans) can be overridden.
+func (c *Config) setEnv() error {
+	for _, value := range []reflect.Value{reflect.ValueOf(c).Elem(), reflect.ValueOf(c.Config).Elem()} {
+		for i := range value.NumField() {
+			name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" || !envKind(value.Type().Field(i).Type) {
+				continue
+			}
+
+			env, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(name))
+			if !ok {
+				continue
+			}
+
+			field := value.Field(i)
+			if field.Kind() == reflect.String {
+				field.SetString(env)
+				continue
+			}
+
+			if err := yaml.Unmarshal([]byte(env), field.Addr().Interface()); err != nil {
+				return fmt.Errorf("%w %s%s: %w", ErrEnvVar, EnvPrefix, strings.ToUpper(name), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// envKind returns true for types that can be set from one environment variable.
+func envKind(kind reflect.Type) bool {
+	if kind.Kind() == reflect.Pointer {
+		kind = kind.Elem()
+	}
+
+	switch kind.Kind() { //nolint:exhaustive
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
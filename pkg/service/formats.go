@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Config file formats.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// ErrFormat is returned for an unknown config file format.
+var ErrFormat = errors.New("unknown config format")
+
+// configExts are the file extensions read from a config directory.
+var configExts = []string{".yaml", ".yml", ".json", ".toml"} //nolint:gochecknoglobals
+
+// yamlLineRegexp matches the line number at the start of a YAML decoding error.
+var yamlLineRegexp = regexp.MustCompile(`^line \d+: `) //nolint:gochecknoglobals
+
+// FileFormat returns the format of a config file. A non-empty format is checked and returned.
+// Otherwise the format comes from the file extension, and unknown extensions are YAML.
+func FileFormat(file, format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case FormatYAML, FormatJSON, FormatTOML:
+		return format, nil
+	case "yml":
+		return FormatYAML, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: %s", ErrFormat, format)
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	default:
+		return FormatYAML, nil
+	}
+}
+
+// decodeNode parses a config file into a YAML document, so every format is read into the
+// config structures the same way. JSON and TOML are decoded with their own parsers first.
+// JSON is not always YAML: YAML rejects some JSON escapes, like \/.
+func decodeNode(data []byte, format string) (*yaml.Node, error) {
+	if format != FormatYAML && len(bytes.TrimSpace(data)) > 0 {
+		value, err := decode(data, format)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = yaml.Marshal(value); err != nil {
+			return nil, fmt.Errorf("converting %s: %w", format, err)
+		}
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", format, err)
+	}
+
+	return &node, nil
+}
+
+// decodeError removes YAML line numbers from errors for JSON and TOML files. Those files
+// are read through a YAML document, so the line numbers do not match the file.
+func decodeError(err error, format string) error {
+	var typeErr *yaml.TypeError
+	if format == FormatYAML || !errors.As(err, &typeErr) {
+		return err
+	}
+
+	msgs := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		msgs[i] = yamlLineRegexp.ReplaceAllString(msg, "")
+	}
+
+	return fmt.Errorf("%s: %s", format, strings.Join(msgs, "; "))
+}
+
+// jsonDates marks quoted dates in a JSON document as timestamps. YAML reads quoted
+// values as strings, and a string like "2024-06-01" cannot be read into a time.
+func jsonDates(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode && node.Style == yaml.DoubleQuotedStyle {
+		if _, err := time.Parse(time.DateOnly, node.Value); err == nil {
+			node.Tag = "!!timestamp"
+		}
+	}
+
+	for _, child := range node.Content {
+		jsonDates(child)
+	}
+}
+
+// jsonTimes converts dates in decoded JSON to times, like jsonDates does for config
+// files, so formats with dates write them as dates and not as strings.
+func jsonTimes(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, val := range value {
+			value[key] = jsonTimes(val)
+		}
+	case []any:
+		for i, val := range value {
+			value[i] = jsonTimes(val)
+		}
+	case string:
+		if date, err := time.Parse(time.DateOnly, value); err == nil {
+			return date
+		}
+	}
+
+	return value
+}
+
+// Convert translates a config file from one format to another. Values are not
+// checked or changed, so ${VAR} placeholders are kept. Comments are not kept.
+// Dates in JSON strings are written as dates.
+func Convert(data []byte, from, to string) ([]byte, error) {
+	value, err := decode(data, from)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == FormatJSON {
+		jsonTimes(value)
+	}
+
+	var buf bytes.Buffer
+
+	switch to {
+	case FormatYAML:
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2) //nolint:mnd
+		err = encoder.Encode(value)
+	case FormatJSON:
+		encoder := json.NewEncoder(&buf)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(value)
+	case FormatTOML:
+		err = toml.NewEncoder(&buf).Encode(value)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrFormat, to)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("writing %s: %w", to, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decode parses a config file into maps, lists and values.
+func decode(data []byte, format string) (map[string]any, error) {
+	value := make(map[string]any)
+
+	switch format {
+	case FormatYAML:
+		if err := yaml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("parsing yaml: %w", err)
+		}
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("parsing json: %w", err)
+		}
+	case FormatTOML:
+		if err := toml.Unmarshal(data, &value); err != nil {
+			return nil, fmt.Errorf("parsing toml: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrFormat, format)
+	}
+
+	normal, _ := normalize(value).(map[string]any)
+
+	return normal, nil
+}
+
+// normalize converts decoded values into types every format can write.
+// TOML has no null, so empty values are removed.
+func normalize(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, val := range value {
+			if val == nil {
+				delete(value, key)
+			} else {
+				value[key] = normalize(val)
+			}
+		}
+
+		return value
+	case []any:
+		for i, val := range value {
+			value[i] = normalize(val)
+		}
+
+		return value
+	case []map[string]any:
+		list := make([]any, len(value))
+		for i, val := range value {
+			list[i] = normalize(val)
+		}
+
+		return list
+	case json.Number:
+		if number, err := value.Int64(); err == nil {
+			return number
+		}
+
+		number, _ := value.Float64()
+
+		return number
+	default:
+		return value
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"golift.io/turbovanityurls/pkg/handler"
+)
+
+// ErrDuplicatePath is returned when two config files define the same path.
+var ErrDuplicatePath = errors.New("duplicate path")
+
+// mergedKeys are handler.Config fields that are merged from every file, instead of replaced.
+var mergedKeys = map[string]bool{"Paths": true, "Profiles": true, "Redirects": true, "Notices": true} //nolint:gochecknoglobals
+
+// configFiles returns the files that make up the config, in the order they are merged.
+// A directory is every .yaml, .yml, .json and .toml file in it, sorted by name. A file is merged
+// last, after the files matched by its include globs. Includes are not recursive.
+func configFiles(configPath string, include []string) ([]string, error) {
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	if info.IsDir() {
+		globs := make([]string, len(configExts))
+		for i, ext := range configExts {
+			globs[i] = filepath.Join(configPath, "*"+ext)
+		}
+
+		files, err := globFiles(globs, "")
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Strings(files)
+
+		return files, nil
+	}
+
+	globs := make([]string, len(include))
+	for i, glob := range include {
+		if !filepath.IsAbs(glob) {
+			// Includes are relative to the file they're in.
+			glob = filepath.Join(filepath.Dir(configPath), glob)
+		}
+
+		globs[i] = glob
+	}
+
+	files, err := globFiles(globs, configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(files, configPath), nil
+}
+
+// globFiles returns the sorted, unique files matched by a list of globs. Skip is never included.
+func globFiles(globs []string, skip string) ([]string, error) {
+	seen := map[string]bool{filepath.Clean(skip): true}
+	files := []string{}
+
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("include %q: %w", glob, err)
+		}
+
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() && !seen[filepath.Clean(match)] {
+				seen[filepath.Clean(match)] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// readFragment reads one config file, and replaces environment variables in its values.
+// The format is yaml, json or toml; an empty format uses the file extension.
+func readFragment(file, format string) (*Config, error) {
+	format, err := FileFormat(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	node, err := decodeNode(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", file, err)
+	}
+
+	if err := interpolateNode(node, ""); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", file, err)
+	}
+
+	if format == FormatJSON {
+		jsonDates(node)
+	}
+
+	fragment := &Config{Config: &handler.Config{}}
+	if err := node.Decode(fragment); err != nil {
+		return nil, fmt.Errorf("unmarshaling config file %s: %w", file, decodeError(err, format))
+	}
+
+	return fragment, nil
+}
+
+// merge adds a config file to the config. Paths must be unique across files; origins
+// tracks which file each path came from. Profiles are merged by name, and redirects and
+// notices are appended. Every other key that is set replaces the value from earlier files.
+func (c *Config) merge(fragment *Config, file string, origins map[string]string) error {
+	for path, pathConfig := range fragment.Paths {
+		if other, ok := origins[path]; ok {
+			return fmt.Errorf("%w: %s is in %s and %s", ErrDuplicatePath, path, other, file)
+		}
+
+		origins[path] = file
+		c.Paths[path] = pathConfig
+	}
+
+	for name, profile := range fragment.Profiles {
+		c.Profiles[name] = profile
+	}
+
+	c.Redirects = append(c.Redirects, fragment.Redirects...)
+	c.Notices = append(c.Notices, fragment.Notices...)
+
+	dst, src := reflect.ValueOf(c.Config).Elem(), reflect.ValueOf(fragment.Config).Elem()
+	for i := range dst.NumField() {
+		if field := dst.Type().Field(i); field.IsExported() && !mergedKeys[field.Name] && !src.Field(i).IsZero() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+
+	if fragment.BDPath != "" {
+		c.BDPath = fragment.BDPath
+	}
+
+	if fragment.BDCache != nil {
+		c.BDCache = fragment.BDCache
+	}
+
+	return nil
+}
+
+// stamp returns a string that changes when any config file changes, or files are added or removed.
+func (c *Config) stamp() string {
+	files, err := configFiles(c.path, c.Include)
+	if err != nil {
+		return err.Error()
+	}
+
+	var stamp strings.Builder
+
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(&stamp, "%s:%d:%d;", file, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+
+	return stamp.String()
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"golift.io/turbovanityurls/pkg/handler"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Config formats from other vanity import path servers.
+const (
+	ImportGovanityurls = "govanityurls"
+	ImportSally        = "sally"
+)
+
+// ErrImport is returned when a config cannot be imported.
+var ErrImport = errors.New("cannot import config")
+
+// govanityConfig is a config file for github.com/GoogleCloudPlatform/govanityurls.
+type govanityConfig struct {
+	Host     string  `yaml:"host"`
+	CacheAge *uint64 `yaml:"cache_max_age"`
+	Paths    map[string]struct {
+		Repo    string `yaml:"repo"`
+		Display string `yaml:"display"`
+		VCS     string `yaml:"vcs"`
+	} `yaml:"paths"`
+}
+
+// sallyConfig is a config file for go.uber.org/sally.
+type sallyConfig struct {
+	URL   string `yaml:"url"`
+	Godoc struct {
+		Host string `yaml:"host"`
+	} `yaml:"godoc"`
+	Packages map[string]struct {
+		Repo        string `yaml:"repo"`
+		Branch      string `yaml:"branch"`
+		URL         string `yaml:"url"`
+		Desc        string `yaml:"desc"`
+		Description string `yaml:"description"`
+		VCS         string `yaml:"vcs"`
+	} `yaml:"packages"`
+}
+
+// knownImportKeys are the keys each format has, by location. Other keys are reported, and not imported.
+var knownImportKeys = map[string]map[string][]string{ //nolint:gochecknoglobals
+	ImportGovanityurls: {"": {"host", "cache_max_age", "paths"}, "paths": {"repo", "display", "vcs"}},
+	ImportSally: {
+		"": {"url", "godoc", "packages"}, "godoc": {"host"},
+		"packages": {"repo", "branch", "url", "desc", "description", "vcs"},
+	},
+}
+
+// Import converts a govanityurls or sally config file into a turbovanityurls YAML config.
+// An empty format is detected from the file's keys. Anything that cannot be represented
+// is returned as a warning, and also written as a comment at the top of the new config.
+func Import(data []byte, from string) ([]byte, []string, error) {
+	raw := make(map[string]any)
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("%w: parsing yaml: %w", ErrImport, err)
+	}
+
+	if from == "" {
+		switch {
+		case raw["packages"] != nil || raw["url"] != nil:
+			from = ImportSally
+		case raw["paths"] != nil:
+			from = ImportGovanityurls
+		default:
+			return nil, nil, fmt.Errorf("%w: no paths or packages found; provide the format", ErrImport)
+		}
+	}
+
+	var (
+		config   *Config
+		warnings []string
+		err      error
+	)
+
+	switch from {
+	case ImportGovanityurls:
+		config, warnings, err = importGovanity(data)
+	case ImportSally:
+		config, warnings, err = importSally(data)
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown format: %s", ErrImport, from)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	warnings = append(unknownKeys(raw, knownImportKeys[from], ""), warnings...)
+
+	var output bytes.Buffer
+
+	encoder := yaml.NewEncoder(&output)
+	encoder.SetIndent(2) //nolint:mnd
+
+	if err := encoder.Encode(config); err != nil {
+		return nil, nil, fmt.Errorf("writing yaml: %w", err)
+	}
+
+	// Check the new config like -validate does. handler.New changes the config, so check a copy.
+	// A missing host was already reported.
+	check := &Config{Config: &handler.Config{}}
+	if err := yaml.Unmarshal(output.Bytes(), check); err != nil {
+		return nil, nil, fmt.Errorf("reading imported config: %w", err)
+	}
+
+	if _, err := handler.New(check.Config); err != nil && check.Host != "" {
+		warnings = append(warnings, "the imported config has errors: "+err.Error())
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# Imported from a %s config.\n", from)
+
+	for _, warning := range warnings {
+		fmt.Fprintf(&buf, "# WARNING: %s\n", warning)
+	}
+
+	buf.Write(output.Bytes())
+
+	return buf.Bytes(), warnings, nil
+}
+
+// importGovanity converts a govanityurls config. Every govanityurls setting has the same name here.
+func importGovanity(data []byte) (*Config, []string, error) {
+	var govanity govanityConfig
+	if err := yaml.Unmarshal(data, &govanity); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrImport, err)
+	}
+
+	config := &Config{Config: &handler.Config{
+		Host:     govanity.Host,
+		CacheAge: govanity.CacheAge,
+		Paths:    make(map[string]*handler.PathConfig),
+	}}
+	warnings := []string{}
+
+	if govanity.Host == "" {
+		warnings = append(warnings, "host is not set; govanityurls used the request's host, "+
+			"but turbovanityurls requires it: add it before using this config")
+	}
+
+	for path, pathConfig := range govanity.Paths {
+		config.Paths[path] = &handler.PathConfig{Repo: pathConfig.Repo, Display: pathConfig.Display, VCS: pathConfig.VCS}
+	}
+
+	return config, warnings, nil
+}
+
+// importSally converts a sally config. Packages become paths, desc becomes description,
+// and a branch becomes a display setting, so source links point at that branch.
+func importSally(data []byte) (*Config, []string, error) {
+	var sally sallyConfig
+	if err := yaml.Unmarshal(data, &sally); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrImport, err)
+	}
+
+	config := &Config{Config: &handler.Config{Host: sally.URL, Paths: make(map[string]*handler.PathConfig)}}
+	warnings := []string{}
+
+	if sally.URL == "" {
+		warnings = append(warnings, "url is not set; turbovanityurls requires host: add it before using this config")
+	}
+
+	if host := strings.Trim(sally.Godoc.Host, "/"); host != "" && host != "pkg.go.dev" {
+		config.DocsURL = "https://" + strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://") + "/{import}"
+	}
+
+	for _, name := range sortedKeys(sally.Packages) {
+		pkg := sally.Packages[name]
+		repo := pkg.Repo
+
+		if repo == "" {
+			warnings = append(warnings, fmt.Sprintf("packages.%s: repo is not set; the package was not imported", name))
+			continue
+		}
+
+		if !strings.Contains(repo, "://") {
+			repo = "https://" + repo
+		}
+
+		pathConfig := &handler.PathConfig{Repo: repo, Description: pkg.Description, VCS: pkg.VCS}
+		if pkg.Desc != "" {
+			pathConfig.Description = pkg.Desc
+		}
+
+		if pkg.Branch != "" && pkg.Branch != "master" {
+			pathConfig.Display = fmt.Sprintf("%[1]s %[1]s/tree/%[2]s{/dir} %[1]s/blob/%[2]s{/dir}/{file}#L{line}", repo, pkg.Branch)
+		}
+
+		if pkg.URL != "" && pkg.URL != sally.URL {
+			warnings = append(warnings, fmt.Sprintf("packages.%s: url %s is not supported; "+
+				"turbovanityurls serves one host, so it is imported under %s", name, pkg.URL, sally.URL))
+		}
+
+		config.Paths["/"+strings.Trim(name, "/")] = pathConfig
+	}
+
+	return config, warnings, nil
+}
+
+// unknownKeys returns a warning for every key that is not in the known list for its location.
+// Keys inside paths and packages are checked against the list for paths or packages.
+func unknownKeys(raw map[string]any, known map[string][]string, where string) []string {
+	warnings := []string{}
+
+	for _, key := range sortedKeys(raw) {
+		location := strings.TrimPrefix(where+"."+key, ".")
+
+		if !slices.Contains(known[where], key) {
+			warnings = append(warnings, fmt.Sprintf("%s is not supported and was not imported", location))
+			continue
+		}
+
+		children, ok := raw[key].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case key == "paths" || key == "packages":
+			for _, name := range sortedKeys(children) {
+				if item, ok := children[name].(map[string]any); ok {
+					for _, warning := range unknownKeys(item, map[string][]string{"": known[key]}, "") {
+						warnings = append(warnings, location+"."+name+"."+warning)
+					}
+				}
+			}
+		case known[key] != nil:
+			warnings = append(warnings, unknownKeys(children, known, key)...)
+		}
+	}
+
+	return warnings
+}
+
+// sortedKeys returns the keys of a map in order.
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
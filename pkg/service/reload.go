@@ -0,0 +1,67 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"golift.io/turbovanityurls/pkg/handler"
+)
+
+// reloader serves requests with the current handler. The handler is replaced when the config changes.
+type reloader struct {
+	current atomic.Pointer[handler.Handler]
+	stamp   string
+}
+
+// ServeHTTP satisfies the http.Handler interface.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.current.Load().ServeHTTP(w, req)
+}
+
+// watch checks every config file for changes, and reloads the config when one changes.
+// A config with errors is logged, and the running config is kept.
+// The badgedata path and cache cannot change without a restart.
+func (c *Config) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := c.Reload(); err != nil {
+			log.Printf("[ERROR] Reloading config, keeping the running config: %v", err)
+		}
+	}
+}
+
+// Reload parses the config files again if any of them changed, and replaces the running
+// handler. Request stats are kept. Returns an error, and keeps the running config, if the
+// changed config cannot be used. A config with errors is not read again until it changes.
+func (c *Config) Reload() error {
+	stamp := c.stamp()
+	if stamp == c.handler.stamp {
+		return nil
+	}
+
+	c.handler.stamp = stamp
+
+	config := &Config{flags: c.flags}
+	if err := config.ParseConfig(c.path); err != nil {
+		return err
+	}
+
+	vanityHandler, err := handler.New(config.Config)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	vanityHandler.KeepStats(c.handler.current.Load())
+
+	c.Config = config.Config
+	c.Include = config.Include
+	c.handler.current.Store(vanityHandler)
+	log.Printf("Reloaded config: %s (%d paths)", c.path, len(config.Paths))
+
+	return nil
+}
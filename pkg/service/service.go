@@ -16,17 +16,21 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"golift.io/badgedata"
 	_ "golift.io/badgedata/grafana" // we use grafana here.
+	"golift.io/turbovanityurls/pkg/bdcache"
 	"golift.io/turbovanityurls/pkg/handler"
 	yaml "gopkg.in/yaml.v3"
 )
@@ -37,12 +41,21 @@ type Flags struct {
 	Timeout    time.Duration
 	ConfigPath string
 	ShowVer    bool
+	Validate   bool
+	Expand     bool
+	Reload     time.Duration
+	Format     string
 }
 
 type Config struct {
 	*handler.Config `yaml:",inline"`
-	BDPath          string `yaml:"bd_path,omitempty"`
-	flags           *Flags
+	BDPath          string          `yaml:"bd_path,omitempty"`
+	BDCache         *bdcache.Config `yaml:"bd_cache,omitempty"`
+	// Include is a list of globs for more config files, relative to this file.
+	Include []string `yaml:"include,omitempty"`
+	flags   *Flags
+	path    string
+	handler *reloader
 }
 
 const defaultTimeout = 15 * time.Second
@@ -58,10 +71,14 @@ func ParseFlags(args []string) *Flags {
 	flag.DurationVar(&f.Timeout, "t", defaultTimeout, "HTTP request timeout")
 	flag.StringVar(&f.ListenAddr, "l", f.ListenAddr, "HTTP server listen address")
 	flag.StringVar(&f.ConfigPath, "c", DefaultConfFile, "config file path")
+	flag.StringVar(&f.Format, "f", "", "config file format: yaml, json or toml; default is from the file extension")
 	flag.BoolVar(&f.ShowVer, "v", false, "show version and exit")
+	flag.DurationVar(&f.Reload, "r", 0, "check config files for changes at this interval and reload them; 0 disables")
+	flag.BoolVar(&f.Validate, "validate", false, "check the config file, print warnings and exit")
+	flag.BoolVar(&f.Expand, "expand", false, "with -validate, print the config with defaults and profiles expanded")
 
 	flag.Usage = func() {
-		fmt.Println("Usage: turbovanityurls [-c <config-file>] [-l <listen-address>] [-t <timeout>]")
+		fmt.Println("Usage: turbovanityurls [-c <config-file>] [-f <format>] [-l <listen-address>] [-t <timeout>] [-r <interval>] [-validate [-expand]]")
 		flag.PrintDefaults()
 	}
 
@@ -81,28 +98,117 @@ func Setup(flags *Flags) (*Config, error) {
 		return nil, fmt.Errorf("config file: %w", err)
 	}
 
+	config.handler = &reloader{stamp: config.stamp()}
+	config.handler.current.Store(vanityHandler)
+
 	if config.BDPath != "" {
-		http.Handle(config.BDPath, badgedata.Handler())
+		http.Handle(config.BDPath, bdcache.New(badgedata.Handler(), config.BDPath, config.BDCache))
 	}
 
-	http.Handle("/", vanityHandler)
+	http.Handle("/", config.handler)
 
 	return config, nil
 }
 
+// Validate parses the config file and builds the handler without starting the server.
+// Returns the config, with defaults expanded, and the warnings found in it.
+// Returns an error if the config cannot be used.
+func Validate(flags *Flags) (*Config, []string, error) {
+	config := &Config{flags: flags}
+	if err := config.ParseConfig(flags.ConfigPath); err != nil {
+		return nil, nil, err
+	}
+
+	// Do not write state files while validating.
+	stateDir := config.StateDir
+	config.StateDir = ""
+
+	vanityHandler, err := handler.New(config.Config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config file: %w", err)
+	}
+
+	config.StateDir = stateDir
+
+	return config, vanityHandler.Warnings(), nil
+}
+
+// Expanded returns the config as YAML, after handler.New expanded it.
+// Defaults, profiles and profile names are left out, because every path already has their values.
+// Includes are left out too, because their contents are merged in.
+func (c *Config) Expanded() ([]byte, error) {
+	expanded := *c
+	expanded.Include = nil
+	handlerConfig := *c.Config
+	handlerConfig.Defaults = nil
+	handlerConfig.Profiles = nil
+	handlerConfig.Paths = make(map[string]*handler.PathConfig, len(c.Paths))
+	expanded.Config = &handlerConfig
+
+	// Copy the paths, so the running config keeps its profile names.
+	for path, pathConfig := range c.Paths {
+		pathCopy := *pathConfig
+		pathCopy.Profile = ""
+		handlerConfig.Paths[path] = &pathCopy
+	}
+
+	data, err := yaml.Marshal(&expanded)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling config: %w", err)
+	}
+
+	return data, nil
+}
+
+// ParseConfig reads a config file, or a directory of config files, and the files it includes.
 func (c *Config) ParseConfig(configPath string) error {
 	if _, err := os.Stat(configPath); os.IsNotExist(err) && configPath == DefaultConfFile {
 		log.Printf("Default Config File Not Found: %s - trying ./config.yaml", configPath)
 		configPath = "config.yaml"
 	}
 
-	data, err := os.ReadFile(configPath)
+	c.path = configPath
+	c.Config = &handler.Config{Paths: make(map[string]*handler.PathConfig), Profiles: make(map[string]*handler.PathConfig)}
+
+	var (
+		main   *Config
+		format string
+	)
+
+	if c.flags != nil {
+		format = c.flags.Format
+	}
+
+	if info, err := os.Stat(configPath); err == nil && !info.IsDir() {
+		if main, err = readFragment(configPath, format); err != nil {
+			return err
+		}
+
+		c.Include = main.Include
+	}
+
+	files, err := configFiles(configPath, c.Include)
 	if err != nil {
-		return fmt.Errorf("reading config file: %w", err)
+		return err
 	}
 
-	if err := yaml.Unmarshal(data, c); err != nil {
-		return fmt.Errorf("unmarshaling config file: %w", err)
+	origins := make(map[string]string)
+
+	for _, file := range files {
+		fragment := main
+		if file != configPath {
+			if fragment, err = readFragment(file, ""); err != nil {
+				return err
+			}
+		}
+
+		if err := c.merge(fragment, file, origins); err != nil {
+			return err
+		}
+	}
+
+	if err := c.setEnv(); err != nil {
+		return err
 	}
 
 	if c.Title == "" {
@@ -127,9 +233,42 @@ func (c *Config) Start() error {
 		ReadHeaderTimeout: c.flags.Timeout,
 	}
 
+	done := make(chan struct{})
+	go c.shutdown(server, done)
+
+	if c.flags.Reload > 0 {
+		go c.watch(c.flags.Reload)
+	}
+
 	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return fmt.Errorf("web server problem: %w", err)
 	}
 
+	// ListenAndServe returns as soon as shutdown begins. Wait for the running
+	// requests to finish, then save request stats, so no requests are lost.
+	<-done
+
+	if err := c.handler.current.Load().SaveStats(); err != nil {
+		return fmt.Errorf("saving stats: %w", err)
+	}
+
 	return nil
 }
+
+// shutdown stops the web server when the process is interrupted or terminated.
+// The done channel is closed after the running requests finish.
+func (c *Config) shutdown(server *http.Server, done chan struct{}) {
+	defer close(done)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	log.Printf("Caught signal %v, shutting down.", <-signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.flags.Timeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("[ERROR] Shutting down web server: %v", err)
+	}
+}
@@ -1,9 +1,17 @@
 package service_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	"golift.io/turbovanityurls/pkg/service"
 )
@@ -108,3 +116,440 @@ func TestParseConfig(t *testing.T) {
 		t.Errorf("parseConfig must return n error with an invalid config file")
 	}
 }
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatalf("writing test file: %v", err)
+		}
+	}
+}
+
+func TestParseConfigDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"00-site.yaml": "host: foo.com\ntitle: Site\nredirects:\n  - match: /a\n    target: /b\n",
+		"10-tools.yml": "title: Tools\npaths:\n  /tool:\n    repo: https://github.com/foo/tool\n",
+		"20-libs.yaml": "paths:\n  /lib:\n    repo: https://github.com/foo/lib\nredirects:\n  - match: /c\n    target: /d\n",
+		"notes.txt":    "not: yaml: [",
+	})
+
+	c := &service.Config{}
+	if err := c.ParseConfig(dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.Host != "foo.com" || c.Title != "Tools" {
+		t.Errorf("later files must override earlier ones: host=%s title=%s", c.Host, c.Title)
+	}
+
+	if len(c.Paths) != 2 || c.Paths["/tool"] == nil || c.Paths["/lib"] == nil {
+		t.Errorf("paths from every file must be merged: %v", c.Paths)
+	}
+
+	if len(c.Redirects) != 2 {
+		t.Errorf("redirects from every file must be appended: %d", len(c.Redirects))
+	}
+}
+
+func TestParseConfigInclude(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, dir, map[string]string{
+		"config.yaml":      "host: foo.com\ntitle: Main\ninclude: [conf.d/*.yaml]\npaths:\n  /main:\n    repo: https://github.com/foo/main\n",
+		"conf.d/one.yaml":  "title: One\ndescription: From one\npaths:\n  /one:\n    repo: https://github.com/foo/one\n",
+		"conf.d/skip.yml":  "paths:\n  /skip:\n    repo: https://github.com/foo/skip\n",
+		"conf.d/two.yaml":  "profiles:\n  foo:\n    repo: https://github.com/foo/{name}\n",
+		"conf.d/other.txt": "ignored",
+	})
+
+	c := &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if c.Title != "Main" || c.Description != "From one" {
+		t.Errorf("the main file must win, and included keys must be kept: title=%s description=%s",
+			c.Title, c.Description)
+	}
+
+	if len(c.Paths) != 2 || c.Paths["/skip"] != nil || c.Profiles["foo"] == nil {
+		t.Errorf("only files matching the include globs must be merged: %v", c.Paths)
+	}
+}
+
+func TestParseConfigDuplicatePath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.yaml": "host: foo.com\npaths:\n  /dup:\n    repo: https://github.com/foo/a\n",
+		"b.yaml": "paths:\n  /dup:\n    repo: https://github.com/foo/b\n",
+	})
+
+	err := (&service.Config{}).ParseConfig(dir)
+	if !errors.Is(err, service.ErrDuplicatePath) {
+		t.Fatalf("duplicate paths must return an error: %v", err)
+	}
+
+	if !strings.Contains(err.Error(), "a.yaml") || !strings.Contains(err.Error(), "b.yaml") {
+		t.Errorf("the error must name both files: %v", err)
+	}
+}
+
+//nolint:paralleltest // Uses t.Setenv.
+func TestParseConfigEnv(t *testing.T) {
+	t.Setenv("TVU_TEST_HOST", "env.example.com")
+	t.Setenv("TVU_TEST_EMPTY", "")
+	t.Setenv("TVU_TITLE", "From Env")
+	t.Setenv("TVU_BD_PATH", "/bd")
+	t.Setenv("TVU_CACHE_MAX_AGE", "60")
+	t.Setenv("TVU_HIDE_DEPRECATED", "true")
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "host: ${TVU_TEST_HOST}\n" +
+			"title: File\n" +
+			"# ${TVU_TEST_UNSET} in a comment is ignored.\n" +
+			"description: ${TVU_TEST_EMPTY:-default text}\n" +
+			"src: ${TVU_TEST_EMPTY}\n" +
+			"redirects:\n" +
+			"  - match: /old/(?P<name>.*)\n" +
+			"    type: regex\n" +
+			"    target: /new/${name}/$${NAME}/${1}\n" +
+			"paths:\n" +
+			"  /gopdf:\n" +
+			"    repo: https://${TVU_TEST_HOST}/gopdf\n" +
+			"    cache_max_age: ${TVU_TEST_UNSET:-3600}\n",
+		"missing.yaml": "host: ${TVU_TEST_UNSET}\n",
+		"invalid.yaml": "host: foo.com\n",
+	})
+
+	c := &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "config.yaml")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	switch {
+	case c.Host != "env.example.com":
+		t.Errorf("${VAR} must be replaced: %s", c.Host)
+	case c.Description != "default text" || c.Src != "":
+		t.Errorf("defaults must be used for empty variables: %q %q", c.Description, c.Src)
+	case c.Paths["/gopdf"].Repo != "https://env.example.com/gopdf":
+		t.Errorf("variables must be replaced inside values: %s", c.Paths["/gopdf"].Repo)
+	case c.Paths["/gopdf"].CacheAge == nil || *c.Paths["/gopdf"].CacheAge != 3600:
+		t.Errorf("numbers from variables must still be numbers: %v", c.Paths["/gopdf"].CacheAge)
+	case c.Redirects[0].Target != "/new/${name}/${NAME}/${1}":
+		t.Errorf("escaped and numbered placeholders must be kept: %s", c.Redirects[0].Target)
+	case c.Title != "From Env" || c.BDPath != "/bd/" || !c.HideDeprecated:
+		t.Errorf("TVU_ variables must override the file: %s %s %v", c.Title, c.BDPath, c.HideDeprecated)
+	case c.CacheAge == nil || *c.CacheAge != 60:
+		t.Errorf("TVU_CACHE_MAX_AGE must override the file: %v", c.CacheAge)
+	}
+
+	err := (&service.Config{}).ParseConfig(filepath.Join(dir, "missing.yaml"))
+	if !errors.Is(err, service.ErrEnvVar) || !strings.Contains(err.Error(), "TVU_TEST_UNSET") {
+		t.Errorf("unset variables must return an error naming them: %v", err)
+	}
+
+	t.Setenv("TVU_CACHE_MAX_AGE", "sixty")
+
+	err = (&service.Config{}).ParseConfig(filepath.Join(dir, "invalid.yaml"))
+	if !errors.Is(err, service.ErrEnvVar) {
+		t.Errorf("invalid TVU_ values must return an error: %v", err)
+	}
+}
+
+func TestParseConfigFormats(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json": `{"host": "foo.com", "include": ["conf.d.toml"], "paths": {"/json": ` +
+			`{"repo": "https://github.com/foo/json", "moved_at": "2024-06-01", "cache_max_age": 60}}}`,
+		"conf.d.toml": "title = \"TOML\"\n[paths.\"/toml\"]\nrepo = \"https://github.com/foo/toml\"\n" +
+			"publish_at = 2024-06-01T12:00:00Z\nredir_paths = [\"wiki\"]\n",
+		"config.conf":  "host = \"flag.com\"\n",
+		"escaped.json": `{"host":"example.com","paths":{"/a":{"repo":"https:\/\/github.com\/x\/a"}}}`,
+		"broken.json":  "{\"host\": ",
+		"badtype.json": "{\n  \"host\": \"example.com\",\n  \"paths\": {\n    \"/a\": {\n" +
+			"      \"repo\": \"https://github.com/x/a\",\n      \"cache_max_age\": \"soon\"\n    }\n  }\n}\n",
+		"badtype.toml": "host = \"example.com\"\n\n[paths.\"/a\"]\nrepo = \"https://github.com/x/a\"\ncache_max_age = \"soon\"\n",
+	})
+
+	c := &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "config.json")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	switch json, toml := c.Paths["/json"], c.Paths["/toml"]; {
+	case c.Host != "foo.com" || c.Title != "TOML":
+		t.Errorf("json and toml files must be merged: %s %s", c.Host, c.Title)
+	case json == nil || json.MovedAt.Day() != 1 || json.CacheAge == nil || *json.CacheAge != 60:
+		t.Errorf("json values must be decoded: %+v", json)
+	case toml == nil || toml.PublishAt.Hour() != 12 || len(toml.RedirPaths) != 1:
+		t.Errorf("toml values must be decoded: %+v", toml)
+	}
+
+	c = &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "escaped.json")); err != nil || c.Paths["/a"].Repo != "https://github.com/x/a" {
+		t.Errorf("json escapes must be decoded: %v", err)
+	}
+
+	c = &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "config.conf")); err == nil {
+		t.Errorf("a file with an unknown extension must be parsed as yaml")
+	}
+
+	c, _, err := service.Validate(&service.Flags{ConfigPath: filepath.Join(dir, "config.conf"), Format: "toml"})
+	if err != nil || c.Host != "flag.com" {
+		t.Errorf("the format flag must override the file extension: %v", err)
+	}
+
+	if err := (&service.Config{}).ParseConfig(filepath.Join(dir, "broken.json")); err == nil ||
+		!strings.Contains(err.Error(), "broken.json") {
+		t.Errorf("json errors must name the file: %v", err)
+	}
+
+	// JSON and TOML are read through YAML, so YAML line numbers would point at the wrong lines.
+	for _, file := range []string{"badtype.json", "badtype.toml"} {
+		err := (&service.Config{}).ParseConfig(filepath.Join(dir, file))
+		if err == nil || !strings.Contains(err.Error(), "cannot unmarshal") ||
+			strings.Contains(err.Error(), "line ") || strings.Contains(err.Error(), "yaml:") {
+			t.Errorf("%s: errors must not have yaml line numbers: %v", file, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	yamlConfig := []byte("host: foo.com\ncache_max_age: 60\nredir_paths: [wiki]\n" +
+		"paths:\n  /foo:\n    repo: https://${ORG:-github.com/foo}/foo\n    moved_at: 2024-06-01\n    description:\n")
+
+	for _, format := range []string{service.FormatJSON, service.FormatTOML, service.FormatYAML} {
+		converted, err := service.Convert(yamlConfig, service.FormatYAML, format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+
+		back, err := service.Convert(converted, format, service.FormatYAML)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n%s", format, err, converted)
+		}
+
+		// JSON has no dates, so they are quoted strings in JSON. They are still read as dates.
+		back = bytes.ReplaceAll(back, []byte(`"`), nil)
+
+		if expect := "host: foo.com\npaths:\n  /foo:\n    moved_at: 2024-06-01T00:00:00Z\n" +
+			"    repo: https://${ORG:-github.com/foo}/foo\nredir_paths:\n  - wiki\n"; !strings.Contains(string(back), expect) ||
+			!strings.Contains(string(back), "cache_max_age: 60\n") {
+			t.Errorf("%s: converted config does not match:\n%s", format, back)
+		}
+	}
+
+	if _, err := service.Convert(yamlConfig, service.FormatYAML, "xml"); !errors.Is(err, service.ErrFormat) {
+		t.Errorf("unknown formats must return an error: %v", err)
+	}
+
+	jsonConfig := []byte(`{"host": "foo.com", "paths": {"/foo": {"repo": "https://github.com/foo/foo", "expire_at": "2030-06-01"}}}`)
+	dir := t.TempDir()
+
+	for _, format := range []string{service.FormatYAML, service.FormatTOML} {
+		converted, err := service.Convert(jsonConfig, service.FormatJSON, format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", format, err)
+		}
+
+		file := filepath.Join(dir, "config."+format)
+		writeFiles(t, dir, map[string]string{"config." + format: string(converted)})
+
+		c := &service.Config{}
+		if err := c.ParseConfig(file); err != nil {
+			t.Fatalf("%s: converted JSON dates must load: %v\n%s", format, err, converted)
+		}
+
+		if foo := c.Paths["/foo"]; foo == nil || !foo.ExpireAt.Equal(time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("%s: converted JSON date is wrong: %+v", format, foo)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	sally := []byte("url: go.uber.org\ndisable_index: true\npackages:\n" +
+		"  zap:\n    repo: github.com/uber-go/zap\n    desc: Logging.\n" +
+		"  thriftrw:\n    repo: github.com/thriftrw/thriftrw-go\n    branch: dev\n    url: go.thriftrw.org\n")
+
+	data, warnings, err := service.Import(sally, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(warnings) != 2 || !strings.Contains(warnings[0], "disable_index") || !strings.Contains(warnings[1], "go.thriftrw.org") {
+		t.Errorf("unsupported keys and package urls must be flagged: %v", warnings)
+	}
+
+	if !bytes.Contains(data, []byte("# WARNING: disable_index")) {
+		t.Errorf("warnings must be written into the new config:\n%s", data)
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"sally.yaml": string(data)})
+
+	c := &service.Config{}
+	if err := c.ParseConfig(filepath.Join(dir, "sally.yaml")); err != nil {
+		t.Fatalf("imported config must be valid: %v\n%s", err, data)
+	}
+
+	switch zap, thriftrw := c.Paths["/zap"], c.Paths["/thriftrw"]; {
+	case c.Host != "go.uber.org":
+		t.Errorf("url must be imported as host: %s", c.Host)
+	case zap == nil || zap.Repo != "https://github.com/uber-go/zap" || zap.Description != "Logging." || zap.Display != "":
+		t.Errorf("repo and desc must be imported: %+v", zap)
+	case thriftrw == nil || !strings.Contains(thriftrw.Display, "thriftrw-go/tree/dev{/dir}"):
+		t.Errorf("branch must be imported as display: %+v", thriftrw)
+	}
+
+	govanity := []byte("host: foo.com\ncache_max_age: 60\npaths:\n" +
+		"  /hg:\n    repo: https://bitbucket.org/foo/hg\n    vcs: hg\n    private: true\n")
+
+	data, warnings, err = service.Import(govanity, service.ImportGovanityurls)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "paths./hg.private") {
+		t.Errorf("unsupported keys must be flagged: %v", warnings)
+	}
+
+	if !bytes.Contains(data, []byte("cache_max_age: 60\npaths:\n  /hg:\n    repo: https://bitbucket.org/foo/hg\n    vcs: hg\n")) {
+		t.Errorf("govanityurls paths must be imported unchanged:\n%s", data)
+	}
+
+	if _, _, err := service.Import([]byte("title: foo\n"), ""); !errors.Is(err, service.ErrImport) {
+		t.Errorf("unknown configs must return an error: %v", err)
+	}
+}
+
+func TestExpanded(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "host: foo.com\nprofiles:\n  lib:\n    repo: https://github.com/foo/{name}\n" +
+			"paths:\n  /bar:\n    profile: lib\n",
+	})
+
+	config, _, err := service.Validate(&service.Flags{ConfigPath: filepath.Join(dir, "config.yaml")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := config.Expanded()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bytes.Contains(data, []byte("profile")) || !bytes.Contains(data, []byte("repo: https://github.com/foo/bar")) {
+		t.Errorf("expanded config must have profile values without profile names:\n%s", data)
+	}
+
+	if config.Paths["/bar"].Profile != "lib" {
+		t.Errorf("expanding must not change the running config")
+	}
+
+	// The expanded config must be usable as a config file.
+	writeFiles(t, dir, map[string]string{"expanded.yaml": string(data)})
+
+	if _, _, err := service.Validate(&service.Flags{ConfigPath: filepath.Join(dir, "expanded.yaml")}); err != nil {
+		t.Errorf("expanded config must be valid: %v\n%s", err, data)
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"00-site.yaml": "host: foo.com\n",
+		"10-a.yaml":    "paths:\n  /a:\n    repo: https://github.com/foo/a\n",
+		"20-b.yaml":    "paths:\n  /b:\n    repo: https://github.com/foo/b\n",
+	})
+
+	// Setup serves the config with the default mux, so only this test may call it.
+	c, err := service.Setup(&service.Flags{ConfigPath: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := httptest.NewServer(http.DefaultServeMux)
+	defer s.Close()
+
+	get := func(path string) string {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("http.Get: %v", err)
+		}
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+
+		return string(data)
+	}
+
+	get("/b?go-get=1")
+
+	if err := c.Reload(); err != nil || len(c.Paths) != 2 {
+		t.Errorf("unchanged files must not reload: %v %v", err, c.Paths)
+	}
+
+	// Changed and added files.
+	writeFiles(t, dir, map[string]string{
+		"20-b.yaml": "paths:\n  /b:\n    repo: https://github.com/foo/bee\n",
+		"30-c.yaml": "paths:\n  /c:\n    repo: https://github.com/foo/c\n",
+	})
+
+	if err := c.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(c.Paths) != 3 || c.Paths["/b"].Repo != "https://github.com/foo/bee" || c.Paths["/c"] == nil {
+		t.Errorf("changed and added files must be reloaded: %v", c.Paths)
+	}
+
+	if body := get("/c"); !strings.Contains(body, "https://github.com/foo/c") {
+		t.Errorf("added paths must be served:\n%s", body)
+	}
+
+	// Removed files.
+	if err := os.Remove(filepath.Join(dir, "10-a.yaml")); err != nil {
+		t.Fatalf("removing test file: %v", err)
+	}
+
+	if err := c.Reload(); err != nil || len(c.Paths) != 2 || c.Paths["/a"] != nil {
+		t.Errorf("removed files must be reloaded: %v %v", err, c.Paths)
+	}
+
+	// A broken file keeps the running config.
+	writeFiles(t, dir, map[string]string{"30-c.yaml": "paths: [\n"})
+
+	if err := c.Reload(); err == nil || len(c.Paths) != 2 || c.Paths["/c"] == nil {
+		t.Errorf("broken files must keep the running config: %v %v", err, c.Paths)
+	}
+
+	// Request stats are not saved without state_dir, so they must move to the new handler.
+	if body := get("/b/-/badge/fetches.svg"); !strings.Contains(body, "go get: 1") {
+		t.Errorf("request stats must be kept when the config is reloaded:\n%s", body)
+	}
+}
@@ -22,6 +22,23 @@ var Index = template.Must(template.New("index").Funcs(Funcs).Parse(`<!DOCTYPE ht
   <link rel='icon' href='/favicon.ico' type='image/x-icon'/ >
   <meta name="author" content="Copyright 2019-{{currentYear}} - {{.Title}}">
   <meta name="viewport" content="width=device-width, initial-scale=1">
+{{- if not .Social.Disable}}
+
+  <!-- social previews -->
+  <meta property="og:type" content="website">
+  <meta property="og:title" content="{{html (or .Social.Title .Title)}}">
+  <meta property="og:description" content="{{html (or .Social.Description .Description)}}">
+  <meta property="og:url" content="https://{{.Host}}/">
+{{- with (or .Social.ImageURL .LogoURL)}}
+  <meta property="og:image" content="{{html .}}">{{end}}
+  <meta name="twitter:card" content="{{html .Social.Card}}">
+{{- with .Social.TwitterSite}}
+  <meta name="twitter:site" content="{{html .}}">{{end}}
+  <meta name="twitter:title" content="{{html (or .Social.Title .Title)}}">
+  <meta name="twitter:description" content="{{html (or .Social.Description .Description)}}">
+{{- end}}
+{{- if .FeedPath}}
+  <link rel="alternate" type="application/atom+xml" title="{{.Title}}" href="{{.FeedPath}}">{{end}}
   <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
 
   <!-- these are in static/css -->
@@ -37,6 +54,12 @@ var Index = template.Must(template.New("index").Funcs(Funcs).Parse(`<!DOCTYPE ht
       <img height="200px" src="{{.LogoURL}}">
     </div>
 {{end}}
+{{- range .ActiveNotices}}
+    <!-- notice -->
+    <div class="row" style="margin-top: 2%; padding: 1rem; border: 2px solid #2980b9; border-radius: 4px; background: #eaf2fa">
+      {{.Message}}
+    </div>
+{{- end}}
     <!-- header content -->
     <div class="row" style="margin-top: 5%">
       <div class="two-thirds column">
@@ -56,15 +79,21 @@ var Index = template.Must(template.New("index").Funcs(Funcs).Parse(`<!DOCTYPE ht
       <div class="one-third column value-prop">
         <h5>Go Modules</h5>
         <ul>
-{{- range .Paths}} {{if and .Repo (not .Wildcard) (not .Name)}}
+{{- range .Paths}} {{if and .Listed (not .Deprecated)}}
           <li><a href="{{.Path}}">{{TrimPrefix .Path "/"}}</a></li>{{end}}{{- end}}
         </ul>
+{{- if .ShowDeprecated}}
+        <h5>Deprecated</h5>
+        <ul>
+{{- range .Paths}} {{if and .Listed .Deprecated}}
+          <li><a href="{{.Path}}">{{TrimPrefix .Path "/"}}</a>{{with .ReplacedBy}} &rarr; {{html .}}{{end}}</li>{{end}}{{- end}}
+        </ul>{{end}}
       </div>
 
       <div class="one-third column value-prop">
         <h5>Applications</h5>
         <ul>
-	{{- range .Paths}} {{if .Name}}
+	{{- range .Paths}} {{if and .Name (not .Unlisted) .Active}}
           <li><a href="{{.Redir}}">{{.Name}}</a></li>{{end}}{{- end}}
         </ul>
       </div>
@@ -104,6 +133,22 @@ var Vanity = template.Must(template.New("vanity").Funcs(Funcs).Parse(`<!DOCTYPE
   <meta name="description" content="{{.RepoPath}}">
   <meta name="author" content="Copyright 2019-{{currentYear}} - {{.IndexTitle}}">
   <meta name="viewport" content="width=device-width, initial-scale=1">
+{{- if not .Social.Disable}}
+
+  <!-- social previews and structured data -->
+  <meta property="og:type" content="website">
+  <meta property="og:title" content="{{html .MetaTitle}}">
+  <meta property="og:description" content="{{html .MetaDescription}}">
+  <meta property="og:url" content="https://{{.Host}}{{.ImportPath}}">
+{{- with .MetaImage}}
+  <meta property="og:image" content="{{html .}}">{{end}}
+  <meta name="twitter:card" content="{{html .Social.Card}}">
+{{- with .Social.TwitterSite}}
+  <meta name="twitter:site" content="{{html .}}">{{end}}
+  <meta name="twitter:title" content="{{html .MetaTitle}}">
+  <meta name="twitter:description" content="{{html .MetaDescription}}">
+  <script type="application/ld+json">{{.JSONLD}}</script>
+{{- end}}
   <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
 
   <!-- these are in static/css -->
@@ -119,6 +164,19 @@ var Vanity = template.Must(template.New("vanity").Funcs(Funcs).Parse(`<!DOCTYPE
       <img height="150px" src="{{.ImageURL}}">
     </div>
 {{end}}
+{{- range .ActiveNotices}}
+    <!-- notice -->
+    <div class="row" style="margin-top: 2%; padding: 1rem; border: 2px solid #2980b9; border-radius: 4px; background: #eaf2fa">
+      {{.Message}}
+    </div>
+{{- end}}
+{{- if .Deprecated}}
+    <!-- deprecation banner -->
+    <div class="row" style="margin-top: 5%; padding: 1rem; border: 2px solid #c0392b; border-radius: 4px; background: #fdecea">
+      <strong>Deprecated:</strong> {{.Host}}{{.ImportPath}} is no longer maintained.
+{{- with .ReplacedBy}} Use <a href="{{html $.ReplacedByURL}}">{{html .}}</a> instead.{{end}}
+    </div>
+{{- end}}
     <!-- main content -->
     <div class="row" style="margin-top: 5%">
       <div class="two-thirds column">
@@ -137,8 +195,8 @@ var Vanity = template.Must(template.New("vanity").Funcs(Funcs).Parse(`<!DOCTYPE
     <!-- built-in links -->
     <div class="value-props row">
       <div class="one-third column value-prop">
-        <form action="https://godoc.org/{{.Host}}{{.ImportPath}}" method="get">
-          <input type="button" class="button button-primary" onClick="window.location.href = 'https://godoc.org/{{.Host}}{{.ImportPath}}';" value="Documentation"/>
+        <form action="{{.DocsLink}}" method="get">
+          <input type="button" class="button button-primary" onClick="window.location.href = '{{.DocsLink}}';" value="Documentation"/>
         </form>
       </div>
       <div class="one-third column value-prop">
@@ -158,6 +216,9 @@ var Vanity = template.Must(template.New("vanity").Funcs(Funcs).Parse(`<!DOCTYPE
   "{{.Host}}{{.ImportPath}}"
 )</code></pre>
         <p>Refer to the package as <code>{{.Title}}</code></p>
+{{- if .Badges}}
+        <p>Add a badge to your README.</p>
+        <pre><code>{{html .BadgeMarkdown}}</code></pre>{{end}}
       </div>
       <div class="one-third column value-prop">
 {{- if .LogoURL}}
@@ -170,3 +231,287 @@ var Vanity = template.Must(template.New("vanity").Funcs(Funcs).Parse(`<!DOCTYPE
   </div>
 </body>
 </html>`))
+
+// Listing is a directory-style page for a namespace prefix or a bare wildcard path.
+var Listing = template.Must(template.New("listing").Funcs(Funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <title>{{.Host}}{{.Title}} - {{.IndexTitle}}</title>
+  <link rel="icon" href="/favicon.ico" type="image/x-icon"/>
+  <meta name="author" content="Copyright 2019-{{currentYear}} - {{.IndexTitle}}">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
+
+  <!-- these are in static/css -->
+  <link rel="stylesheet" href="https://docs.golift.io/css/normalize.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/custom.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/skeleton.css">
+</head>
+<body>
+  <div class="container">
+    <!-- main content -->
+    <div class="row" style="margin-top: 5%">
+      <div class="two-thirds column">
+        <h1>{{.Host}}{{.Title}}</h1>
+{{- if .PathConfig}}
+        <p>{{.Description}}</p>{{end}}
+      </div>
+    </div>
+
+    <!-- namespace content -->
+    <div class="value-props row">
+{{- if .Children}}
+      <div class="one-third column value-prop">
+        <h5>Paths</h5>
+        <ul>
+{{- range .Children}}
+          <li><a href="{{.Path}}">{{TrimPrefix .Path "/"}}</a></li>{{end}}
+        </ul>
+      </div>{{end}}
+{{- if .PathConfig}}{{if .KnownRepos}}
+      <div class="one-third column value-prop">
+        <h5>Repositories</h5>
+        <ul>
+{{- range .KnownRepos}}
+          <li><a href="{{$.Prefix}}{{.}}">{{TrimPrefix $.Prefix "/"}}{{.}}</a></li>{{end}}
+        </ul>
+      </div>{{end}}{{end}}
+      <div class="one-third column value-prop">
+{{- if .LogoURL}}
+        <a href="https://{{.Host}}"><img class="value-img" src="{{.LogoURL}}"></a>
+{{- end}}
+        <p>&copy; 2019-{{currentYear}} {{.IndexTitle}}<p>
+      </div>
+    </div>
+
+  </div>
+</body>
+</html>`))
+
+// NotFound is displayed with a 404 status when a path is not configured.
+var NotFound = template.Must(template.New("notfound").Funcs(Funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <title>Not Found - {{.IndexTitle}}</title>
+  <link rel="icon" href="/favicon.ico" type="image/x-icon"/>
+  <meta name="author" content="Copyright 2019-{{currentYear}} - {{.IndexTitle}}">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
+
+  <!-- these are in static/css -->
+  <link rel="stylesheet" href="https://docs.golift.io/css/normalize.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/custom.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/skeleton.css">
+</head>
+<body>
+  <div class="container">
+    <!-- main content -->
+    <div class="row" style="margin-top: 5%">
+      <div class="two-thirds column">
+        <h1>404 page not found</h1>
+        <p><code>{{html .Host}}{{html .Path}}</code> does not exist.</p>
+{{- if .Suggestions}}
+        <h5>Did you mean?</h5>
+        <ul>
+{{- range .Suggestions}}
+          <li><a href="{{.Path}}">{{$.Host}}{{.Path}}</a></li>{{end}}
+        </ul>{{end}}
+        <p><a href="/">Browse all packages</a></p>
+      </div>
+      <div class="one-third column value-prop">
+{{- if .LogoURL}}
+        <a href="https://{{.Host}}"><img class="value-img" src="{{.LogoURL}}"></a>
+{{- end}}
+        <p>&copy; 2019-{{currentYear}} {{.IndexTitle}}<p>
+      </div>
+    </div>
+
+  </div>
+</body>
+</html>`))
+
+// Gone is displayed with a 410 status when a path was removed on purpose.
+var Gone = template.Must(template.New("gone").Funcs(Funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <title>Gone - {{.IndexTitle}}</title>
+  <link rel="icon" href="/favicon.ico" type="image/x-icon"/>
+  <meta name="author" content="Copyright 2019-{{currentYear}} - {{.IndexTitle}}">
+  <meta name="robots" content="noindex">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
+
+  <!-- these are in static/css -->
+  <link rel="stylesheet" href="https://docs.golift.io/css/normalize.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/custom.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/skeleton.css">
+</head>
+<body>
+  <div class="container">
+    <!-- main content -->
+    <div class="row" style="margin-top: 5%">
+      <div class="two-thirds column">
+        <h1>410 gone</h1>
+        <p><code>{{.Host}}{{.Path}}</code> was removed and is no longer available.</p>
+{{- if .Message}}
+        <p>{{.Message}}</p>{{end}}
+        <p><a href="/">Browse all packages</a></p>
+      </div>
+      <div class="one-third column value-prop">
+{{- if .LogoURL}}
+        <a href="https://{{.Host}}"><img class="value-img" src="{{.LogoURL}}"></a>
+{{- end}}
+        <p>&copy; 2019-{{currentYear}} {{.IndexTitle}}<p>
+      </div>
+    </div>
+
+  </div>
+</body>
+</html>`))
+
+// Doc renders package documentation built from a local source checkout.
+// Doc and Decl values are already HTML; everything else is plain text.
+var Doc = template.Must(template.New("doc").Funcs(Funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <title>{{.Name}} - {{html .ImportPath}} - {{.IndexTitle}}</title>
+  <link rel="icon" href="/favicon.ico" type="image/x-icon"/>
+  <meta name="description" content="{{html .Synopsis}}">
+  <meta name="author" content="Copyright 2019-{{currentYear}} - {{.IndexTitle}}">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
+
+  <!-- these are in static/css -->
+  <link rel="stylesheet" href="https://docs.golift.io/css/normalize.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/custom.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/skeleton.css">
+</head>
+<body>
+  <div class="container">
+    <!-- package header -->
+    <div class="row" style="margin-top: 5%">
+      <div class="two-thirds column">
+        <h1>package {{.Name}}</h1>
+        <pre><code>import "{{html .ImportPath}}"</code></pre>
+        <p><a href="{{html .BasePath}}">{{html .BasePath}}</a>{{with .PkgPath}}/{{html .}}{{end}}</p>
+      </div>
+      <div class="one-third column value-prop">
+{{- if .LogoURL}}
+        <a href="https://{{.Host}}"><img class="value-img" src="{{.LogoURL}}"></a>
+{{- end}}
+      </div>
+    </div>
+
+    <!-- package documentation -->
+    <div class="row">
+      <h4>Overview</h4>
+      {{.Doc}}
+{{- range .Examples}}
+      <h5>Example{{with .Name}} ({{.}}){{end}}</h5>
+      {{.Doc}}<pre><code>{{.Code}}</code></pre>{{with .Output}}<p>Output:</p><pre><code>{{.}}</code></pre>{{end}}{{end}}
+
+{{- if .Consts}}
+      <h4>Constants</h4>
+{{- range .Consts}}
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}{{end}}{{end}}
+
+{{- if .Vars}}
+      <h4>Variables</h4>
+{{- range .Vars}}
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}{{end}}{{end}}
+
+{{- if .Funcs}}
+      <h4>Functions</h4>
+{{- range .Funcs}}
+      <h5 id="{{.Name}}">func {{.Name}}</h5>
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}
+{{- range .Examples}}
+      <h6>Example{{with .Name}} ({{.}}){{end}}</h6>
+      {{.Doc}}<pre><code>{{.Code}}</code></pre>{{with .Output}}<p>Output:</p><pre><code>{{.}}</code></pre>{{end}}{{end}}{{end}}{{end}}
+
+{{- if .Types}}
+      <h4>Types</h4>
+{{- range $type := .Types}}
+      <h5 id="{{.Name}}">type {{.Name}}</h5>
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}
+{{- range .Examples}}
+      <h6>Example{{with .Name}} ({{.}}){{end}}</h6>
+      {{.Doc}}<pre><code>{{.Code}}</code></pre>{{with .Output}}<p>Output:</p><pre><code>{{.}}</code></pre>{{end}}{{end}}
+{{- range .Consts}}
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}{{end}}
+{{- range .Vars}}
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}{{end}}
+{{- range .Funcs}}
+      <h6 id="{{.Name}}">func {{.Name}}</h6>
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}
+{{- range .Examples}}
+      <h6>Example{{with .Name}} ({{.}}){{end}}</h6>
+      {{.Doc}}<pre><code>{{.Code}}</code></pre>{{with .Output}}<p>Output:</p><pre><code>{{.}}</code></pre>{{end}}{{end}}{{end}}
+{{- range .Methods}}
+      <h6 id="{{$type.Name}}.{{.Name}}">func ({{$type.Name}}) {{.Name}}</h6>
+      <pre><code>{{.Decl}}</code></pre>{{.Doc}}
+{{- range .Examples}}
+      <h6>Example{{with .Name}} ({{.}}){{end}}</h6>
+      {{.Doc}}<pre><code>{{.Code}}</code></pre>{{with .Output}}<p>Output:</p><pre><code>{{.}}</code></pre>{{end}}{{end}}{{end}}{{end}}{{end}}
+
+{{- if .Subdirs}}
+      <h4>Directories</h4>
+      <ul>
+{{- range .Subdirs}}
+        <li><a href="{{html $.BasePath}}{{with $.PkgPath}}/{{html .}}{{end}}/{{html .}}">{{html .}}</a></li>{{end}}
+      </ul>{{end}}
+    </div>
+
+    <div class="row">
+      <p>&copy; 2019-{{currentYear}} {{.IndexTitle}}<p>
+    </div>
+  </div>
+</body>
+</html>`))
+
+// Stats shows request counters for each import path. Only served with basic auth.
+var Stats = template.Must(template.New("stats").Funcs(Funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta http-equiv="content-type" content="text/html; charset=UTF-8">
+  <title>Stats - {{.Title}}</title>
+  <link rel="icon" href="/favicon.ico" type="image/x-icon"/>
+  <meta name="robots" content="noindex">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link href="https://fonts.googleapis.com/css?family=Raleway:400,300,600" rel="stylesheet" type="text/css">
+
+  <!-- these are in static/css -->
+  <link rel="stylesheet" href="https://docs.golift.io/css/normalize.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/custom.css">
+  <link rel="stylesheet" href="https://docs.golift.io/css/skeleton.css">
+</head>
+<body>
+  <div class="container">
+    <div class="row" style="margin-top: 5%">
+      <h1>{{.Host}} stats</h1>
+      <p>Requests in the last {{.Days}} days.</p>
+      <table class="u-full-width">
+        <thead>
+          <tr><th>Import Path</th><th>go get</th><th>Browser</th><th>Counters</th></tr>
+        </thead>
+        <tbody>
+{{- range .Rows}}
+          <tr>
+            <td><code>{{html $.Host}}{{html .ImportPath}}</code></td>
+            <td>{{.GoGet}}</td>
+            <td>{{.Browser}}</td>
+            <td>{{range $name, $count := .Counters}}{{html $name}}: {{$count}}<br>{{end}}</td>
+          </tr>
+{{- else}}
+          <tr><td colspan="4">No requests yet.</td></tr>
+{{- end}}
+        </tbody>
+      </table>
+    </div>
+  </div>
+</body>
+</html>`))